@@ -0,0 +1,309 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// maxAudioSize specifies the maximum size in bytes of unpacked audio data of a
+// single frame.
+const maxAudioSize = 1 << 24
+
+// ErrUnsupportedAudioCodec is reported when decoding audio data compressed
+// using a codec other than Smacker v2 sound compression; e.g. Bink audio, as
+// indicated by bits 27-26 of the track information.
+var ErrUnsupportedAudioCodec = errors.New("unsupported audio codec")
+
+// NumAudioTracks returns the number of audio tracks with audio data present.
+func (f *File) NumAudioTracks() int {
+	n := 0
+	for _, info := range f.TrackInfo {
+		if info.HasAudioData() {
+			n++
+		}
+	}
+	return n
+}
+
+// Track returns the frequency and format information of the i-th audio track,
+// and reports whether audio data is present for the track. It returns false if
+// i is out of range.
+func (f *File) Track(i int) (TrackInfo, bool) {
+	if i < 0 || i >= len(f.TrackInfo) {
+		return 0, false
+	}
+	info := f.TrackInfo[i]
+	return info, info.HasAudioData()
+}
+
+// DecodeAudio decodes the audio data of the given track stored in the i-th
+// frame of the Smacker file, and returns it as little-endian PCM samples; 8-bit
+// samples are unsigned and 16-bit samples are signed. The samples of stereo
+// tracks are interleaved, left channel first. A nil slice is returned if the
+// frame holds no audio data for the track.
+//
+// Audio data is decoded as part of the sequential decoding of frames; frames
+// between the most recently decoded frame and frame i are decoded as needed,
+// and an error is returned if frame i precedes the most recently decoded frame.
+// Files parsed using ParseReaderAt support random access, as for DecodeFrame.
+func (f *File) DecodeAudio(track, i int) ([]byte, error) {
+	if track < 0 || track >= len(f.TrackInfo) {
+		return nil, errors.Errorf("invalid audio track; expected 0 <= track < %d, got %d", len(f.TrackInfo), track)
+	}
+	if i < 0 || i >= f.NFrames {
+		return nil, errors.Errorf("invalid frame index; expected 0 <= i < %d, got %d", f.NFrames, i)
+	}
+	if i != f.cur-1 {
+		if f.ra != nil {
+			if err := f.seekKeyFrame(i); err != nil {
+				return nil, err
+			}
+		} else if i < f.cur {
+			return nil, errors.Errorf("unable to decode audio of frame %d out of sequence; most recently decoded frame is %d", i, f.cur-1)
+		}
+		if err := f.decodeFrames(i); err != nil {
+			return nil, err
+		}
+	}
+	return f.decodeAudioData(track, i, f.audio[track])
+}
+
+// DecodeAudioTrack decodes the audio data of the given track from every frame
+// of the Smacker file, and returns it as PCM samples in the format of
+// DecodeAudio. Only the audio data of the track is decoded; the video data of
+// each frame is skipped.
+//
+// Files parsed using ParseReaderAt read the audio data directly, leaving the
+// decoder state unaffected. Otherwise, the audio data is read as part of
+// sequential reading of the frames, which must start at the first frame, and
+// no further frames may be decoded afterwards, even if an error is returned;
+// the audio data of the last frame remains available to DecodeAudio.
+func (f *File) DecodeAudioTrack(track int) ([]byte, error) {
+	if track < 0 || track >= len(f.TrackInfo) {
+		return nil, errors.Errorf("invalid audio track; expected 0 <= track < %d, got %d", len(f.TrackInfo), track)
+	}
+	if f.ra == nil && f.cur != 0 {
+		return nil, errors.Errorf("unable to decode audio track %d out of sequence; next frame to decode is %d", track, f.cur)
+	}
+	samples, err := f.decodeAudioTrack(track)
+	if f.ra == nil {
+		// The frame buffer is stale, as the video data of each frame read is
+		// skipped, and the reader is left within the frame data on error.
+		f.err = errors.Errorf("unable to decode frame %d; frame buffer is stale after sequential decoding of audio track %d", f.cur, track)
+	}
+	return samples, err
+}
+
+// decodeAudioTrack decodes the audio data of the given track from every frame
+// of the Smacker file, as for DecodeAudioTrack.
+func (f *File) decodeAudioTrack(track int) ([]byte, error) {
+	out := &bytes.Buffer{}
+	var buf []byte
+	for i := 0; i < f.NFrames; i++ {
+		typ := f.FrameTypes[i]
+		var data []byte
+		if f.ra != nil {
+			if typ&(FrameTypeAudioDataTrack0<<uint(track)) == 0 {
+				continue
+			}
+			if err := f.checkRawFrame(i); err != nil {
+				return nil, err
+			}
+			if n := f.FrameLen(i); cap(buf) < n {
+				buf = make([]byte, n)
+			}
+			data = buf[:f.FrameLen(i)]
+			if err := f.readAt(data, f.offsets[i]); err != nil {
+				return nil, errors.WithMessagef(err, "unable to read frame %d", i)
+			}
+		} else {
+			// Every frame is read to advance the sequential reader, and the
+			// frame buffer is left stale.
+			if err := f.readNextFrame(); err != nil {
+				return nil, errors.WithMessagef(err, "unable to read frame %d", i)
+			}
+			f.cur++
+			data = f.buf
+		}
+		_, audio, _, err := splitFrameData(data, typ)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to decode frame %d", i)
+		}
+		if f.ra == nil {
+			// Keep the audio data of the most recently read frame in sync
+			// with f.cur, for DecodeAudio.
+			f.audio = audio
+		}
+		samples, err := f.decodeAudioData(track, i, audio[track])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(samples)
+	}
+	return out.Bytes(), nil
+}
+
+// decodeAudioData decodes the given audio data of the given track stored in the
+// i-th frame, as for DecodeAudio. A nil slice is returned if data is nil.
+func (f *File) decodeAudioData(track, i int, data []byte) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	info := f.TrackInfo[track]
+	if !info.IsCompressed() {
+		buf := make([]byte, len(data))
+		copy(buf, data)
+		return buf, nil
+	}
+	if !info.IsVersion2() {
+		return nil, errors.Wrapf(ErrUnsupportedAudioCodec, "unable to decode audio of track %d; only v2 compression supported", track)
+	}
+	samples, err := decodeAudioV2(data, info, f.AudioSize[track])
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to decode audio of track %d in frame %d", track, i)
+	}
+	return samples, nil
+}
+
+// DecodeAudioChannels decodes the audio data of the given stereo track stored in
+// the i-th frame of the Smacker file, as for DecodeAudio, and returns the
+// samples of the left and right channel separately. Each channel is
+// reconstructed from its own sample deltas prior to being split.
+func (f *File) DecodeAudioChannels(track, i int) (left, right []byte, err error) {
+	if track < 0 || track >= len(f.TrackInfo) {
+		return nil, nil, errors.Errorf("invalid audio track; expected 0 <= track < %d, got %d", len(f.TrackInfo), track)
+	}
+	info := f.TrackInfo[track]
+	if info.NChannels() != 2 {
+		return nil, nil, errors.Errorf("unable to split channels of mono audio track %d", track)
+	}
+	samples, err := f.DecodeAudio(track, i)
+	if err != nil {
+		return nil, nil, err
+	}
+	if samples == nil {
+		return nil, nil, nil
+	}
+	sampleSize := info.BitRate() / 8
+	n := len(samples) / 2
+	left = make([]byte, 0, n)
+	right = make([]byte, 0, n)
+	for j := 0; j+2*sampleSize <= len(samples); j += 2 * sampleSize {
+		left = append(left, samples[j:j+sampleSize]...)
+		right = append(right, samples[j+sampleSize:j+2*sampleSize]...)
+	}
+	return left, right, nil
+}
+
+// decodeAudioV2 decodes the given audio data compressed using Smacker v2 sound
+// compression, based on the audio format of the track.
+//
+// The audio data consists of the unpacked size in bytes, followed by a
+// bitstream of flags, one 8-bit Huffman tree per byte of a sample and channel,
+// the initial sample of each channel, and Huffman encoded sample deltas (DPCM).
+// The unpacked size is bounded by maxSize, the size of the largest unpacked
+// audio data buffer of the track as stored in the file header; absent and
+// single-leaf trees decode sample deltas without consuming any bits, so the
+// size of the audio data does not bound the unpacked size.
+func decodeAudioV2(data []byte, info TrackInfo, maxSize int) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("invalid audio data; missing unpacked size")
+	}
+	if maxSize > maxAudioSize {
+		maxSize = maxAudioSize
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > maxSize {
+		return nil, errors.Errorf("invalid unpacked audio size; expected <= %d, got %d", maxSize, size)
+	}
+	br := newBitReader(data[4:])
+	present, err := br.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+	if present == 0 {
+		return nil, nil
+	}
+	stereo, err := br.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+	is16, err := br.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+	// Verify audio format.
+	nchannels := int(stereo) + 1
+	if nchannels != info.NChannels() {
+		return nil, errors.Errorf("audio format mismatch; expected %d channels, got %d", info.NChannels(), nchannels)
+	}
+	sampleSize := int(is16) + 1
+	if 8*sampleSize != info.BitRate() {
+		return nil, errors.Errorf("audio format mismatch; expected %d-bit samples, got %d-bit", info.BitRate(), 8*sampleSize)
+	}
+	if size%(nchannels*sampleSize) != 0 || size < nchannels*sampleSize {
+		return nil, errors.Errorf("invalid unpacked audio size %d for %d-channel %d-bit audio", size, nchannels, 8*sampleSize)
+	}
+	// One tree per channel for 8-bit audio, and two trees per channel (low and
+	// high byte) for 16-bit audio.
+	trees := make([]*byteTree, nchannels*sampleSize)
+	for i := range trees {
+		if trees[i], err = parseByteTree(br); err != nil {
+			return nil, errors.WithMessagef(err, "unable to parse audio Huffman tree %d", i)
+		}
+	}
+	buf := make([]byte, size)
+	nsamples := size / sampleSize
+	if is16 == 1 {
+		// The initial samples are stored big-endian, right channel first.
+		var pred [2]int16
+		for c := nchannels - 1; c >= 0; c-- {
+			v, err := br.ReadBits(16)
+			if err != nil {
+				return nil, err
+			}
+			pred[c] = int16(v>>8 | v<<8)
+		}
+		for i := 0; i < nsamples; i++ {
+			c := i % nchannels
+			if i >= nchannels {
+				lo, err := trees[2*c].Decode(br)
+				if err != nil {
+					return nil, err
+				}
+				hi, err := trees[2*c+1].Decode(br)
+				if err != nil {
+					return nil, err
+				}
+				// Deltas wrap around, rather than being clipped.
+				pred[c] += int16(uint16(lo) | uint16(hi)<<8)
+			}
+			binary.LittleEndian.PutUint16(buf[2*i:], uint16(pred[c]))
+		}
+		return buf, nil
+	}
+	// The initial samples are stored right channel first.
+	var pred [2]uint8
+	for c := nchannels - 1; c >= 0; c-- {
+		v, err := br.ReadBits(8)
+		if err != nil {
+			return nil, err
+		}
+		pred[c] = uint8(v)
+	}
+	for i := 0; i < nsamples; i++ {
+		c := i % nchannels
+		if i >= nchannels {
+			delta, err := trees[c].Decode(br)
+			if err != nil {
+				return nil, err
+			}
+			// Deltas wrap around, rather than being clipped.
+			pred[c] += delta
+		}
+		buf[i] = pred[c]
+	}
+	return buf, nil
+}
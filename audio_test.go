@@ -0,0 +1,438 @@
+package smk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"testing"
+)
+
+// Track information of audio tracks compressed using v2 sound compression.
+const (
+	testMono8   = TrackInfo(0xC0000000 | 22050)
+	testMono16  = TrackInfo(0xE0000000 | 22050)
+	testStereo8 = TrackInfo(0xD0000000 | 22050)
+)
+
+// encodeAudio encodes the given samples using Smacker v2 sound compression,
+// and returns the audio data including the unpacked size. The samples of
+// stereo audio are interleaved, left channel first; 8-bit samples are unsigned
+// and 16-bit samples are signed.
+func encodeAudio(samples []int, nchannels int, is16 bool) []byte {
+	sampleSize := 1
+	if is16 {
+		sampleSize = 2
+	}
+	// Sample deltas; one value per byte of a sample, and one tree per byte of a
+	// sample and channel.
+	type delta struct {
+		tree int
+		val  uint32
+	}
+	var deltas []delta
+	pred := append([]int{}, samples[:nchannels]...)
+	for i := nchannels; i < len(samples); i++ {
+		c := i % nchannels
+		d := samples[i] - pred[c]
+		pred[c] = samples[i]
+		if is16 {
+			u := uint16(int16(d))
+			deltas = append(deltas, delta{tree: 2 * c, val: uint32(u & 0xFF)}, delta{tree: 2*c + 1, val: uint32(u >> 8)})
+		} else {
+			deltas = append(deltas, delta{tree: c, val: uint32(uint8(d))})
+		}
+	}
+	vals := make([][]uint32, nchannels*sampleSize)
+	for _, d := range deltas {
+		vals[d.tree] = append(vals[d.tree], d.val)
+	}
+	bw := &bitWriter{}
+	// Present, stereo and 16-bit flags.
+	bw.WriteBit(1)
+	bw.WriteBit(uint32(nchannels - 1))
+	bw.WriteBit(uint32(sampleSize - 1))
+	codes := make([]map[uint32]huffmanCode, len(vals))
+	for i := range vals {
+		codes[i] = writeByteTree(bw, uniq(vals[i]))
+	}
+	// Initial samples, right channel first; 16-bit samples big-endian.
+	for c := nchannels - 1; c >= 0; c-- {
+		if is16 {
+			u := uint16(int16(samples[c]))
+			bw.WriteBits(uint32(u>>8), 8)
+			bw.WriteBits(uint32(u&0xFF), 8)
+		} else {
+			bw.WriteBits(uint32(samples[c]), 8)
+		}
+	}
+	for _, d := range deltas {
+		codes[d.tree][d.val].write(bw)
+	}
+	data := make([]byte, 4, 4+len(bw.Bytes()))
+	binary.LittleEndian.PutUint32(data, uint32(len(samples)*sampleSize))
+	return append(data, bw.Bytes()...)
+}
+
+// uniq returns the sorted set of the given values, or a single zero value if
+// none are given.
+func uniq(vals []uint32) []uint32 {
+	seen := make(map[uint32]bool)
+	var set []uint32
+	for _, val := range vals {
+		if !seen[val] {
+			seen[val] = true
+			set = append(set, val)
+		}
+	}
+	if len(set) == 0 {
+		return []uint32{0}
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i] < set[j] })
+	return set
+}
+
+// audioChunk returns the audio chunk of a frame holding the given audio data,
+// padded to a multiple of 4 bytes and preceded by the chunk size.
+func audioChunk(data []byte) []byte {
+	n := len(data)
+	for n%4 != 0 {
+		n++
+	}
+	chunk := make([]byte, 4+n)
+	binary.LittleEndian.PutUint32(chunk, uint32(4+n))
+	copy(chunk[4:], data)
+	return chunk
+}
+
+func TestDecodeAudioMono16(t *testing.T) {
+	samples := []int{1000, -3, 200, -32768, 32767, 5, 5, 5, -1, 1234}
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testMono16
+	hdr.AudioSize[0] = 2 * len(samples)
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack0, data: audioChunk(encodeAudio(samples, 1, true))},
+	})
+	f := parseBytes(t, raw)
+	pcm, err := f.DecodeAudio(0, 0)
+	if err != nil {
+		t.Fatalf("unable to decode audio; %+v", err)
+	}
+	if len(pcm) != 2*len(samples) {
+		t.Fatalf("expected %d bytes of audio, got %d", 2*len(samples), len(pcm))
+	}
+	sample := func(i int) int {
+		return int(int16(binary.LittleEndian.Uint16(pcm[2*i:])))
+	}
+	// The first sample is the initial sample of the channel.
+	if got, want := sample(0), samples[0]; got != want {
+		t.Errorf("first sample mismatch; expected %d, got %d", want, got)
+	}
+	last := len(samples) - 1
+	if got, want := sample(last), samples[last]; got != want {
+		t.Errorf("last sample mismatch; expected %d, got %d", want, got)
+	}
+	for i, want := range samples {
+		if got := sample(i); got != want {
+			t.Errorf("sample %d mismatch; expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestDecodeAudioSizeLimit(t *testing.T) {
+	// Mono 8-bit audio data of 1 MiB with an absent tree; the sample deltas
+	// are decoded without consuming any bits.
+	bw := &bitWriter{}
+	bw.WriteBit(1)      // present
+	bw.WriteBit(0)      // mono
+	bw.WriteBit(0)      // 8-bit
+	bw.WriteBit(0)      // absent tree
+	bw.WriteBits(42, 8) // initial sample
+	data := make([]byte, 4, 4+len(bw.Bytes()))
+	binary.LittleEndian.PutUint32(data, 1<<20)
+	data = append(data, bw.Bytes()...)
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testMono8
+	hdr.AudioSize[0] = 1024
+	var frames []testFrame
+	for i := 0; i < 16; i++ {
+		frames = append(frames, testFrame{key: i == 0, typ: FrameTypeAudioDataTrack0, data: audioChunk(data)})
+	}
+	f := parseBytes(t, buildFile(t, hdr, absentTrees, frames))
+	if _, err := f.DecodeAudio(0, 0); err == nil {
+		t.Error("expected error for unpacked size beyond the size stored in the file header")
+	}
+	f = parseBytes(t, buildFile(t, hdr, absentTrees, frames))
+	if _, err := f.DecodeAudioTrack(0); err == nil {
+		t.Error("expected error for unpacked size beyond the size stored in the file header")
+	}
+	hdr.AudioSize[0] = maxAudioSize + 1
+	f = parseBytes(t, buildFile(t, hdr, absentTrees, frames))
+	if err := f.Validate(); !errors.Is(err, ErrInvalidAudioSize) {
+		t.Errorf("expected ErrInvalidAudioSize, got %v", err)
+	}
+}
+
+func TestDecodeAudioTruncated(t *testing.T) {
+	samples := []int{1000, -3, 200, -32768, 32767, 5, 5, 5, -1, 1234}
+	data := encodeAudio(samples, 1, true)
+	if _, err := decodeAudioV2(data, testMono16, 2*len(samples)); err != nil {
+		t.Fatalf("unable to decode audio; %+v", err)
+	}
+	for n := 0; n < len(data); n++ {
+		if _, err := decodeAudioV2(data[:n], testMono16, 2*len(samples)); err == nil {
+			t.Errorf("expected error for audio data truncated to %d of %d bytes", n, len(data))
+		}
+	}
+}
+
+func TestDecodeAudioChannels(t *testing.T) {
+	// Interleaved samples; the left channel rises and the right channel falls.
+	samples := []int{10, 200, 20, 190, 30, 180, 255, 0, 0, 255}
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testStereo8
+	hdr.TrackInfo[1] = testMono8
+	hdr.AudioSize[0] = len(samples)
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack0, data: audioChunk(encodeAudio(samples, 2, false))},
+	})
+	f := parseBytes(t, raw)
+	left, right, err := f.DecodeAudioChannels(0, 0)
+	if err != nil {
+		t.Fatalf("unable to decode audio channels; %+v", err)
+	}
+	var wantLeft, wantRight []byte
+	for i := 0; i < len(samples); i += 2 {
+		wantLeft = append(wantLeft, byte(samples[i]))
+		wantRight = append(wantRight, byte(samples[i+1]))
+	}
+	if !bytes.Equal(left, wantLeft) {
+		t.Errorf("left channel mismatch; expected %v, got %v", wantLeft, left)
+	}
+	if !bytes.Equal(right, wantRight) {
+		t.Errorf("right channel mismatch; expected %v, got %v", wantRight, right)
+	}
+	if _, _, err := f.DecodeAudioChannels(1, 0); err == nil {
+		t.Error("expected error for mono audio track")
+	}
+	if _, _, err := f.DecodeAudioChannels(7, 0); err == nil {
+		t.Error("expected error for invalid audio track")
+	}
+}
+
+func TestTrack(t *testing.T) {
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testMono16
+	hdr.TrackInfo[2] = testStereo8
+	// Track information without audio data.
+	hdr.TrackInfo[3] = TrackInfo(0x80000000 | 44100)
+	f := parseBytes(t, buildFile(t, hdr, absentTrees, []testFrame{{key: true}}))
+	if got := f.NumAudioTracks(); got != 2 {
+		t.Errorf("expected 2 audio tracks, got %d", got)
+	}
+	golden := []struct {
+		track int
+		info  TrackInfo
+		ok    bool
+	}{
+		{track: 0, info: testMono16, ok: true},
+		{track: 1},
+		{track: 2, info: testStereo8, ok: true},
+		{track: 3, info: TrackInfo(0x80000000 | 44100)},
+		{track: -1},
+		{track: 7},
+	}
+	for _, g := range golden {
+		info, ok := f.Track(g.track)
+		if info != g.info || ok != g.ok {
+			t.Errorf("track %d: expected (0x%08X, %v), got (0x%08X, %v)", g.track, uint32(g.info), g.ok, uint32(info), ok)
+		}
+	}
+	if info, _ := f.Track(2); info.SampleRate() != 22050 || info.NChannels() != 2 || info.BitRate() != 8 {
+		t.Errorf("track 2: format mismatch; expected 2 channels of 8-bit at 22050 Hz, got %d channels of %d-bit at %d Hz", info.NChannels(), info.BitRate(), info.SampleRate())
+	}
+}
+
+func TestDecodeAudioFrameTypeMismatch(t *testing.T) {
+	// The track information reports uncompressed audio data for track 0 only,
+	// whereas the frame type reports audio data for track 1 and 3; the frame
+	// type is authoritative for the presence of audio data.
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = TrackInfo(0x40000000 | 8000)
+	track1 := []byte{1, 2, 3, 4}
+	track3 := []byte{9, 9, 0, 0, 7, 7, 7, 7}
+	data := append(audioChunk(track1), audioChunk(track3)...)
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack1 | FrameTypeAudioDataTrack3, data: data},
+	})
+	for _, ra := range []bool{false, true} {
+		f := parseBytes(t, raw)
+		if ra {
+			f = parseBytesAt(t, raw)
+		}
+		if _, err := f.DecodeFrame(0); err != nil {
+			t.Fatalf("random access %v: unable to decode video following the audio data; %+v", ra, err)
+		}
+		want := [][]byte{nil, track1, nil, track3}
+		for track, w := range want {
+			got, err := f.DecodeAudio(track, 0)
+			if err != nil {
+				t.Fatalf("random access %v: track %d: unable to decode audio; %+v", ra, track, err)
+			}
+			if !bytes.Equal(got, w) || (got == nil) != (w == nil) {
+				t.Errorf("random access %v: track %d: audio mismatch; expected %v, got %v", ra, track, w, got)
+			}
+		}
+	}
+	got, err := parseBytesAt(t, raw).DecodeAudioTrack(3)
+	if err != nil {
+		t.Fatalf("unable to decode audio track; %+v", err)
+	}
+	if !bytes.Equal(got, track3) {
+		t.Errorf("audio track mismatch; expected %v, got %v", track3, got)
+	}
+}
+
+func TestDecodeAudioUnsupportedCodec(t *testing.T) {
+	// Compressed audio data of track 0, using a codec other than v2 sound
+	// compression; as indicated by bit 27 of the track information.
+	hdr := FileHeader{Signature: "SMK4"}
+	hdr.TrackInfo[0] = TrackInfo(0xC8000000 | 22050)
+	hdr.AudioSize[0] = 4
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack0, data: audioChunk([]byte{1, 2, 3, 4})},
+	})
+	if _, err := parseBytes(t, raw).DecodeAudio(0, 0); !errors.Is(err, ErrUnsupportedAudioCodec) {
+		t.Errorf("expected ErrUnsupportedAudioCodec by DecodeAudio, got %v", err)
+	}
+	if _, err := parseBytesAt(t, raw).DecodeAudioTrack(0); !errors.Is(err, ErrUnsupportedAudioCodec) {
+		t.Errorf("expected ErrUnsupportedAudioCodec by DecodeAudioTrack, got %v", err)
+	}
+	if err := parseBytes(t, raw).WriteTrackWAV(&bytes.Buffer{}, 0); !errors.Is(err, ErrUnsupportedAudioCodec) {
+		t.Errorf("expected ErrUnsupportedAudioCodec by WriteTrackWAV, got %v", err)
+	}
+}
+
+// audioFile returns a Smacker file of the given dimensions and number of
+// frames, with stereo 8-bit audio data of track 0 in every other frame, and
+// mono 16-bit audio data of track 1 in every frame.
+func audioFile(t testing.TB, width, height, nframes int) []byte {
+	t.Helper()
+	hdr := FileHeader{Width: width, Height: height}
+	hdr.TrackInfo[0] = testStereo8
+	hdr.TrackInfo[1] = testMono16
+	hdr.AudioSize[0] = 8
+	hdr.AudioSize[1] = 8
+	var frames []testFrame
+	for i := 0; i < nframes; i++ {
+		frame := testFrame{key: i == 0, typ: FrameTypeAudioDataTrack1}
+		if i%2 == 0 {
+			frame.typ |= FrameTypeAudioDataTrack0
+			frame.data = audioChunk(encodeAudio([]int{128, 10, i, 20, 255, 0, 0, i}, 2, false))
+		}
+		frame.data = append(frame.data, audioChunk(encodeAudio([]int{1000, -i, 200, i}, 1, true))...)
+		frames = append(frames, frame)
+	}
+	return buildFile(t, hdr, absentTrees, frames)
+}
+
+func TestDecodeAudioTrack(t *testing.T) {
+	raw := audioFile(t, 8, 8, 5)
+	for track := 0; track < 2; track++ {
+		// Audio data of each frame, as decoded by DecodeAudio.
+		var frames [][]byte
+		var want []byte
+		f := parseBytes(t, raw)
+		for i := 0; i < f.NFrames; i++ {
+			pcm, err := f.DecodeAudio(track, i)
+			if err != nil {
+				t.Fatalf("track %d: frame %d: unable to decode audio; %+v", track, i, err)
+			}
+			frames = append(frames, pcm)
+			want = append(want, pcm...)
+		}
+		for _, ra := range []bool{false, true} {
+			f := parseBytes(t, raw)
+			if ra {
+				f = parseBytesAt(t, raw)
+			}
+			got, err := f.DecodeAudioTrack(track)
+			if err != nil {
+				t.Fatalf("random access %v: track %d: unable to decode audio track; %+v", ra, track, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("random access %v: track %d: audio mismatch; expected %v, got %v", ra, track, want, got)
+			}
+			// The audio data of the last frame remains available.
+			last := f.NFrames - 1
+			pcm, err := f.DecodeAudio(track, last)
+			if err != nil {
+				t.Fatalf("random access %v: track %d: unable to decode audio of last frame; %+v", ra, track, err)
+			}
+			if !bytes.Equal(pcm, frames[last]) {
+				t.Errorf("random access %v: track %d: audio mismatch of last frame; expected %v, got %v", ra, track, frames[last], pcm)
+			}
+		}
+	}
+	f := parseBytes(t, raw)
+	if _, err := f.DecodeFrame(1); err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	if _, err := f.DecodeAudioTrack(0); err == nil {
+		t.Error("expected error for sequential decoding not starting at the first frame")
+	}
+}
+
+func TestDecodeAudioTrackStale(t *testing.T) {
+	raw := audioFile(t, 8, 8, 5)
+	// No frames may be decoded after sequential decoding of an audio track.
+	f := parseBytes(t, raw)
+	if _, err := f.DecodeAudioTrack(1); err != nil {
+		t.Fatalf("unable to decode audio track; %+v", err)
+	}
+	if _, err := f.DecodeFrame(f.NFrames - 1); err == nil {
+		t.Error("expected error for decoding frame after sequential decoding of audio track")
+	}
+	if _, err := f.DecodeAudio(1, f.NFrames-1); err != nil {
+		t.Errorf("unable to decode audio of last frame; %+v", err)
+	}
+	// Corrupt the size of the first audio chunk of frame 2, so that decoding of
+	// the audio track fails partway through.
+	corrupt := append([]byte{}, raw...)
+	off := parseBytes(t, raw).FrameOffset(2)
+	binary.LittleEndian.PutUint32(corrupt[off:], 0xFFFF)
+	f = parseBytes(t, corrupt)
+	if _, err := f.DecodeAudioTrack(1); err == nil {
+		t.Fatal("expected error for corrupt audio chunk size")
+	}
+	for i := 3; i < f.NFrames; i++ {
+		if _, err := f.DecodeFrame(i); err == nil {
+			t.Errorf("frame %d: expected error for decoding frame after failed decoding of audio track", i)
+		}
+	}
+	// Random access files bound the frame data by the file size.
+	inflated := append([]byte{}, raw...)
+	binary.LittleEndian.PutUint32(inflated[headerSize+4*2:], 0x7FFFFFFC)
+	if _, err := parseBytesAt(t, inflated).DecodeAudioTrack(1); !errors.Is(err, ErrTruncatedFrameData) {
+		t.Errorf("expected ErrTruncatedFrameData, got %v", err)
+	}
+}
+
+func BenchmarkDecodeAudioTrack(b *testing.B) {
+	raw := audioFile(b, 320, 200, 32)
+	b.Run("audio", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := parseBytes(b, raw).DecodeAudioTrack(1); err != nil {
+				b.Fatalf("unable to decode audio track; %+v", err)
+			}
+		}
+	})
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := parseBytes(b, raw).DecodeAllAudioContext(context.Background(), 1); err != nil {
+				b.Fatalf("unable to decode audio track; %+v", err)
+			}
+		}
+	})
+}
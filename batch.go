@@ -0,0 +1,61 @@
+package smk
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ParseFiles parses the Smacker files of the given paths concurrently, using at
+// most workers goroutines. It returns the successfully parsed files and the
+// parse errors, both keyed by path.
+//
+// Only the file headers are retained; the underlying file of each parsed File
+// is closed before ParseFiles returns, so that the number of open file
+// descriptors is bounded by workers.
+func ParseFiles(paths []string, workers int) (map[string]*File, map[string]error) {
+	if workers < 1 {
+		workers = 1
+	}
+	files := make(map[string]*File)
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				f, err := parseFileMetadata(path)
+				mu.Lock()
+				if err != nil {
+					errs[path] = err
+				} else {
+					files[path] = f
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+	return files, errs
+}
+
+// parseFileMetadata parses the Smacker file of the given path and closes the
+// underlying file.
+func parseFileMetadata(path string) (*File, error) {
+	f, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f.c = nil
+	return f, nil
+}
@@ -0,0 +1,53 @@
+package smk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFiles(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("unable to count open file descriptors; %v", err)
+	}
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.smk")
+	if err := os.WriteFile(valid, encodeFrames(t, testFrames(2, 8, 4), 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.smk")
+	if err := os.WriteFile(corrupt, []byte("SMK9 corrupt"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.smk")
+	paths := []string{valid, corrupt, missing}
+	for _, workers := range []int{0, 1, 2, 8} {
+		files, errs := ParseFiles(paths, workers)
+		if len(files) != 1 || len(errs) != 2 {
+			t.Fatalf("%d workers: expected 1 file and 2 errors, got %d files and %d errors", workers, len(files), len(errs))
+		}
+		f := files[valid]
+		if f == nil {
+			t.Fatalf("%d workers: expected %q parsed, got errors %v", workers, valid, errs)
+		}
+		if f.Width != 8 || f.Height != 4 || f.NFrames != 2 {
+			t.Errorf("%d workers: header mismatch; expected 8x4 with 2 frames, got %dx%d with %d frames", workers, f.Width, f.Height, f.NFrames)
+		}
+		if f.c != nil {
+			t.Errorf("%d workers: expected underlying file of %q closed", workers, valid)
+		}
+		for _, path := range []string{corrupt, missing} {
+			if errs[path] == nil {
+				t.Errorf("%d workers: expected error for %q", workers, path)
+			}
+		}
+	}
+	after, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) > len(fds) {
+		t.Errorf("file descriptor leak; %d open before, %d open after", len(fds), len(after))
+	}
+}
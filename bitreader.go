@@ -0,0 +1,81 @@
+package smk
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// bitReader reads bits from a Smacker bitstream, least-significant bit first
+// within each byte.
+//
+// For instance, the byte sequence 0x01 0x80 yields the bits 1, 0, 0, 0, 0, 0,
+// 0, 0 followed by 0, 0, 0, 0, 0, 0, 0, 1; and reading 16 bits at once yields
+// 0x8001.
+type bitReader struct {
+	// Underlying bitstream.
+	buf []byte
+	// Current bit position within buf.
+	pos int
+}
+
+// newBitReader returns a new bit reader of the given bitstream.
+func newBitReader(buf []byte) *bitReader {
+	return &bitReader{buf: buf}
+}
+
+// ReadBit reads and returns the next bit of the bitstream.
+func (br *bitReader) ReadBit() (uint32, error) {
+	if br.pos >= 8*len(br.buf) {
+		return 0, errors.WithStack(io.ErrUnexpectedEOF)
+	}
+	bit := uint32(br.buf[br.pos>>3]>>uint(br.pos&7)) & 1
+	br.pos++
+	return bit, nil
+}
+
+// ReadBits reads and returns the next n bits of the bitstream, where the first
+// bit read is stored in the least significant bit of the result. At most 32
+// bits may be read at once.
+func (br *bitReader) ReadBits(n uint) (uint32, error) {
+	x, ok := br.PeekBits(n)
+	if !ok {
+		return 0, errors.WithStack(io.ErrUnexpectedEOF)
+	}
+	br.pos += int(n)
+	return x, nil
+}
+
+// PeekBits returns the next n bits of the bitstream as for ReadBits, without
+// consuming them. The boolean result is false if fewer than n bits remain.
+func (br *bitReader) PeekBits(n uint) (uint32, bool) {
+	if br.pos+int(n) > 8*len(br.buf) {
+		return 0, false
+	}
+	var x uint32
+	for i, pos := uint(0), br.pos; i < n; {
+		// Read the remaining bits of the current byte, at most n-i bits.
+		off := uint(pos & 7)
+		m := 8 - off
+		if m > n-i {
+			m = n - i
+		}
+		bits := uint32(br.buf[pos>>3]>>off) & (1<<m - 1)
+		x |= bits << i
+		pos += int(m)
+		i += m
+	}
+	return x, true
+}
+
+// BytesRead returns the number of bytes consumed from the bitstream, including
+// a partially read last byte.
+func (br *bitReader) BytesRead() int {
+	return (br.pos + 7) >> 3
+}
+
+// Align skips the remaining bits of a partially read byte, so that the next bit
+// read is the least significant bit of the following byte.
+func (br *bitReader) Align() {
+	br.pos = br.BytesRead() << 3
+}
@@ -0,0 +1,78 @@
+package smk
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestBitReaderReadBit(t *testing.T) {
+	br := newBitReader([]byte{0x01, 0x80, 0xA5})
+	want := []uint32{
+		1, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 1,
+		1, 0, 1, 0, 0, 1, 0, 1,
+	}
+	for i, w := range want {
+		bit, err := br.ReadBit()
+		if err != nil {
+			t.Fatalf("bit %d: unexpected error; %v", i, err)
+		}
+		if bit != w {
+			t.Errorf("bit %d: expected %d, got %d", i, w, bit)
+		}
+	}
+	if _, err := br.ReadBit(); errors.Cause(err) != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF at end of bitstream, got %v", err)
+	}
+}
+
+func TestBitReaderReadBits(t *testing.T) {
+	golden := []struct {
+		n    uint
+		want uint32
+	}{
+		{n: 16, want: 0x8001},
+		{n: 3, want: 0x5},
+		{n: 5, want: 0x14},
+		{n: 0, want: 0},
+		{n: 12, want: 0xDBC},
+		{n: 4, want: 0xF},
+	}
+	br := newBitReader([]byte{0x01, 0x80, 0xA5, 0xBC, 0xFD})
+	for i, g := range golden {
+		x, err := br.ReadBits(g.n)
+		if err != nil {
+			t.Fatalf("i=%d: unexpected error; %v", i, err)
+		}
+		if x != g.want {
+			t.Errorf("i=%d: expected 0x%X, got 0x%X", i, g.want, x)
+		}
+	}
+	if _, err := br.ReadBits(1); errors.Cause(err) != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF at end of bitstream, got %v", err)
+	}
+}
+
+func TestBitReaderAlign(t *testing.T) {
+	br := newBitReader([]byte{0xFF, 0x02})
+	if _, err := br.ReadBits(3); err != nil {
+		t.Fatal(err)
+	}
+	if got := br.BytesRead(); got != 1 {
+		t.Errorf("expected 1 byte read, got %d", got)
+	}
+	br.Align()
+	x, err := br.ReadBits(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != 2 {
+		t.Errorf("expected bits of second byte after align, got 0x%X", x)
+	}
+	br.Align()
+	if got := br.BytesRead(); got != 2 {
+		t.Errorf("expected 2 bytes read, got %d", got)
+	}
+}
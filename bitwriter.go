@@ -0,0 +1,33 @@
+package smk
+
+// bitWriter writes bits of a Smacker bitstream, least-significant bit first
+// within each byte; i.e. the inverse of bitReader.
+type bitWriter struct {
+	// Underlying bitstream.
+	buf []byte
+	// Current bit position within buf.
+	pos int
+}
+
+// WriteBit writes the least significant bit of bit to the bitstream.
+func (bw *bitWriter) WriteBit(bit uint32) {
+	if bw.pos&7 == 0 {
+		bw.buf = append(bw.buf, 0)
+	}
+	bw.buf[bw.pos>>3] |= uint8(bit&1) << uint(bw.pos&7)
+	bw.pos++
+}
+
+// WriteBits writes the n least significant bits of x to the bitstream, least
+// significant bit first. At most 32 bits may be written at once.
+func (bw *bitWriter) WriteBits(x uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		bw.WriteBit(x >> i)
+	}
+}
+
+// Bytes returns the bitstream, where a partially written last byte is padded
+// with zero bits.
+func (bw *bitWriter) Bytes() []byte {
+	return bw.buf
+}
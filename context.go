@@ -0,0 +1,78 @@
+package smk
+
+import (
+	"bytes"
+	"context"
+	"image"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeAll decodes and returns every video frame of the Smacker file, excluding
+// the ring frame. As every frame is retained, use Frames to iterate over the
+// frames of long videos.
+func (f *File) DecodeAll() ([]*image.Paletted, error) {
+	return f.DecodeAllContext(context.Background())
+}
+
+// DecodeAllContext decodes and returns every video frame of the Smacker file,
+// excluding the ring frame. The context is checked between frames; on
+// cancellation, decoding stops and the context error is returned without any
+// frames.
+func (f *File) DecodeAllContext(ctx context.Context) ([]*image.Paletted, error) {
+	imgs, err := f.decodeAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return imgs, nil
+}
+
+// DecodeAllWithDeadline decodes and returns every video frame of the Smacker
+// file, excluding the ring frame, as for DecodeAllContext. The context is
+// checked between frames; once its deadline is exceeded or it is cancelled,
+// decoding stops and the frames decoded so far are returned together with the
+// context error.
+func (f *File) DecodeAllWithDeadline(ctx context.Context) ([]image.Image, error) {
+	imgs, err := f.decodeAll(ctx)
+	frames := make([]image.Image, len(imgs))
+	for i, img := range imgs {
+		frames[i] = img
+	}
+	return frames, err
+}
+
+// decodeAll decodes the video frames of the Smacker file up to the first error,
+// and returns the frames decoded so far.
+func (f *File) decodeAll(ctx context.Context) ([]*image.Paletted, error) {
+	imgs := make([]*image.Paletted, 0, f.NFrames)
+	for i := 0; i < f.NFrames; i++ {
+		if err := ctx.Err(); err != nil {
+			return imgs, errors.WithStack(err)
+		}
+		img, err := f.DecodeFrame(i)
+		if err != nil {
+			return imgs, err
+		}
+		imgs = append(imgs, img)
+	}
+	return imgs, nil
+}
+
+// DecodeAllAudioContext decodes the audio data of the given track from every
+// frame of the Smacker file, and returns it as PCM samples in the format of
+// DecodeAudio. The context is checked between frames; on cancellation, decoding
+// stops and the context error is returned without any samples.
+func (f *File) DecodeAllAudioContext(ctx context.Context, track int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for i := 0; i < f.NFrames; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		samples, err := f.DecodeAudio(track, i)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(samples)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+package smk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+)
+
+// countdownContext is a context which is cancelled once Err has been called n
+// times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.n <= 0 {
+		return context.Canceled
+	}
+	ctx.n--
+	return nil
+}
+
+func TestDecodeAll(t *testing.T) {
+	want := testFrames(5, 8, 4)
+	f := parseBytes(t, encodeFrames(t, want, 100))
+	imgs, err := f.DecodeAll()
+	if err != nil {
+		t.Fatalf("unable to decode frames; %+v", err)
+	}
+	if len(imgs) != len(want) {
+		t.Fatalf("expected %d frames, got %d", len(want), len(imgs))
+	}
+	for i, img := range imgs {
+		if !bytes.Equal(img.Pix, want[i].Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+	}
+}
+
+func TestDecodeAllContextCancel(t *testing.T) {
+	raw := encodeFrames(t, testFrames(5, 8, 4), 100)
+	// Cancelled before the first frame.
+	f := parseBytes(t, raw)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	imgs, err := f.DecodeAllContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if imgs != nil {
+		t.Errorf("expected no frames on cancellation, got %d", len(imgs))
+	}
+	// Cancelled after the third frame.
+	f = parseBytes(t, raw)
+	imgs, err = f.DecodeAllContext(&countdownContext{Context: context.Background(), n: 3})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if imgs != nil {
+		t.Errorf("expected no frames on cancellation, got %d", len(imgs))
+	}
+	if f.cur != 3 {
+		t.Errorf("expected decoding to stop after frame 2, next frame is %d", f.cur)
+	}
+}
+
+func TestDecodeAllWithDeadline(t *testing.T) {
+	want := testFrames(5, 8, 4)
+	raw := encodeFrames(t, want, 100)
+	imgs, err := parseBytes(t, raw).DecodeAllWithDeadline(context.Background())
+	if err != nil {
+		t.Fatalf("unable to decode frames; %+v", err)
+	}
+	if len(imgs) != len(want) {
+		t.Fatalf("expected %d frames, got %d", len(want), len(imgs))
+	}
+	for i, img := range imgs {
+		if !bytes.Equal(img.(*image.Paletted).Pix, want[i].Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+	}
+	// Deadline exceeded before the first frame.
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	imgs, err = parseBytes(t, raw).DecodeAllWithDeadline(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if len(imgs) != 0 {
+		t.Errorf("expected no frames, got %d", len(imgs))
+	}
+	// The frames decoded before cancellation are returned.
+	imgs, err = parseBytes(t, raw).DecodeAllWithDeadline(&countdownContext{Context: context.Background(), n: 3})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(imgs) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(imgs))
+	}
+	for i, img := range imgs {
+		if !bytes.Equal(img.(*image.Paletted).Pix, want[i].Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+	}
+}
+
+func TestDecodeAllAudioContext(t *testing.T) {
+	samples := []int{1000, -3, 200, -32768, 32767}
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testMono16
+	hdr.AudioSize[0] = 2 * len(samples)
+	chunk := audioChunk(encodeAudio(samples, 1, true))
+	frames := []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack0, data: chunk},
+		{},
+		{typ: FrameTypeAudioDataTrack0, data: chunk},
+	}
+	raw := buildFile(t, hdr, absentTrees, frames)
+	pcm, err := parseBytes(t, raw).DecodeAllAudioContext(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unable to decode audio; %+v", err)
+	}
+	if want := 2 * 2 * len(samples); len(pcm) != want {
+		t.Errorf("expected %d bytes of audio, got %d", want, len(pcm))
+	}
+	pcm, err = parseBytes(t, raw).DecodeAllAudioContext(&countdownContext{Context: context.Background(), n: 1}, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if pcm != nil {
+		t.Errorf("expected no samples on cancellation, got %d bytes", len(pcm))
+	}
+}
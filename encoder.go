@@ -0,0 +1,325 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+
+	"github.com/lunixbochs/struc"
+	"github.com/pkg/errors"
+)
+
+// An Encoder writes video frames to a Smacker file.
+//
+// The encoder produces Smacker version 2 files without audio, where every frame
+// is a key frame made up of full blocks. A palette record is stored for the
+// first frame and for each frame whose palette differs from the preceding
+// frame. Palette colours are stored with 6 bits per component.
+type Encoder struct {
+	// Underlying io.Writer.
+	w io.Writer
+	// Frame dimensions.
+	width, height int
+	// Number of frames.
+	nframes int
+	// Frame rate.
+	rate FrameRate
+	// Pixels of each written frame; one palette index per pixel.
+	frames [][]uint8
+	// Palette record of each written frame, or nil if the palette is unchanged
+	// from the preceding frame.
+	pals [][]byte
+	// Palette record of the most recently written frame.
+	prevPal []byte
+}
+
+// NewEncoder returns a new Encoder for writing nframes video frames of the
+// given dimensions and frame rate to w as a Smacker file. The width and height
+// must be multiples of 4.
+//
+// The frames are buffered in memory until Close, as the sizes stored in the
+// file header and the Huffman trees preceding the frame data are only known
+// once all frames have been written; thus, w need not be seekable.
+func NewEncoder(w io.Writer, width, height, nframes int, rate FrameRate) *Encoder {
+	return &Encoder{
+		w:       w,
+		width:   width,
+		height:  height,
+		nframes: nframes,
+		rate:    rate,
+	}
+}
+
+// WriteFrame writes the given video frame, the dimensions of which must match
+// the frame dimensions of the encoder. The image may be reused by the caller
+// once WriteFrame returns.
+func (e *Encoder) WriteFrame(img *image.Paletted) error {
+	if len(e.frames) >= e.nframes {
+		return errors.Errorf("unable to write frame; all %d frames already written", e.nframes)
+	}
+	if e.width <= 0 || e.height <= 0 || e.width%4 != 0 || e.height%4 != 0 {
+		return errors.Errorf("invalid frame dimensions; expected positive multiples of 4, got %dx%d", e.width, e.height)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() != e.width || bounds.Dy() != e.height {
+		return errors.Errorf("frame dimensions mismatch; expected %dx%d, got %dx%d", e.width, e.height, bounds.Dx(), bounds.Dy())
+	}
+	if len(img.Palette) > 256 {
+		return errors.Errorf("invalid palette size; expected <= 256, got %d", len(img.Palette))
+	}
+	pix := make([]uint8, e.width*e.height)
+	for y := 0; y < e.height; y++ {
+		off := img.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		copy(pix[y*e.width:(y+1)*e.width], img.Pix[off:off+e.width])
+	}
+	rec := paletteRecord(img.Palette)
+	if len(e.frames) > 0 && bytes.Equal(rec, e.prevPal) {
+		e.pals = append(e.pals, nil)
+	} else {
+		e.pals = append(e.pals, rec)
+		e.prevPal = rec
+	}
+	e.frames = append(e.frames, pix)
+	return nil
+}
+
+// Close encodes the written frames and writes the Smacker file to the
+// underlying writer. An error is returned if fewer frames than specified have
+// been written. Close does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if len(e.frames) != e.nframes {
+		return errors.Errorf("unable to encode Smacker file; expected %d frames, got %d", e.nframes, len(e.frames))
+	}
+	// Huffman trees; the mono block trees are absent, and every block type
+	// descriptor is a full block with a run length of one.
+	bw := &bitWriter{}
+	writeHuffmanTree(bw, nil)
+	writeHuffmanTree(bw, nil)
+	fullCodes, err := writeHuffmanTree(bw, e.fullValues())
+	if err != nil {
+		return errors.WithMessage(err, "unable to encode full blocks Huffman tree")
+	}
+	typeCodes, err := writeHuffmanTree(bw, []uint32{blockFull})
+	if err != nil {
+		return errors.WithMessage(err, "unable to encode block type descriptors Huffman tree")
+	}
+	trees := bw.Bytes()
+	// Frame data.
+	frames := make([][]byte, len(e.frames))
+	for i, pix := range e.frames {
+		frames[i] = e.encodeFrame(pix, e.pals[i], fullCodes, typeCodes[blockFull])
+	}
+	hdr := FileHeader{
+		Signature: "SMK2",
+		Width:     e.width,
+		Height:    e.height,
+		NFrames:   e.nframes,
+		FrameRate: e.rate,
+		TreesSize: len(trees),
+		FullSize:  treeAllocSize(len(fullCodes)),
+		TypeSize:  treeAllocSize(len(typeCodes)),
+	}
+	buf := &bytes.Buffer{}
+	if err := struc.Pack(buf, &hdr); err != nil {
+		return errors.WithStack(err)
+	}
+	// Frame size and type tables.
+	sizes := make([]byte, 4*len(frames))
+	types := make([]byte, len(frames))
+	for i, frame := range frames {
+		// Every frame is a key frame.
+		binary.LittleEndian.PutUint32(sizes[4*i:], uint32(len(frame))|1)
+		if e.pals[i] != nil {
+			types[i] = byte(FrameTypePaletteRecord)
+		}
+	}
+	buf.Write(sizes)
+	buf.Write(types)
+	buf.Write(trees)
+	for _, frame := range frames {
+		buf.Write(frame)
+	}
+	if _, err := buf.WriteTo(e.w); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// fullValues returns the sorted set of values stored in full blocks of the
+// written frames; i.e. the pixel pairs of the left and right half of each row
+// of each block.
+func (e *Encoder) fullValues() []uint32 {
+	used := make(map[uint32]bool)
+	for _, pix := range e.frames {
+		for y := 0; y < e.height; y++ {
+			row := pix[y*e.width : (y+1)*e.width]
+			for x := 0; x < e.width; x += 2 {
+				used[uint32(row[x])|uint32(row[x+1])<<8] = true
+			}
+		}
+	}
+	vals := make([]uint32, 0, len(used))
+	for val := range used {
+		vals = append(vals, val)
+	}
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	return vals
+}
+
+// encodeFrame encodes the frame data of the given frame pixels, with an
+// optional palette record, using the given codes of the full blocks tree and
+// the code of the full block type descriptor.
+func (e *Encoder) encodeFrame(pix []uint8, pal []byte, fullCodes map[uint32]huffmanCode, typeCode huffmanCode) []byte {
+	bw := &bitWriter{}
+	for y := 0; y < e.height; y += 4 {
+		for x := 0; x < e.width; x += 4 {
+			typeCode.write(bw)
+			// Each row is stored as two values, right half first.
+			for j := 0; j < 4; j++ {
+				row := pix[(y+j)*e.width+x : (y+j)*e.width+x+4]
+				fullCodes[uint32(row[2])|uint32(row[3])<<8].write(bw)
+				fullCodes[uint32(row[0])|uint32(row[1])<<8].write(bw)
+			}
+		}
+	}
+	data := append(append([]byte{}, pal...), bw.Bytes()...)
+	// Frame sizes are multiples of 4 bytes, as bit 0 and 1 are flags.
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+// paletteRecord returns the palette record of a frame which sets every entry of
+// the palette to the corresponding colour of pal, including the size byte.
+// Entries beyond the length of pal are set to black.
+func paletteRecord(pal color.Palette) []byte {
+	rec := make([]byte, 1, 1+3*256+3)
+	for i := 0; i < 256; i++ {
+		var c color.RGBA
+		if i < len(pal) {
+			c = color.RGBAModel.Convert(pal[i]).(color.RGBA)
+		}
+		// Set entry; 6 bits per colour component.
+		rec = append(rec, c.R>>2, c.G>>2, c.B>>2)
+	}
+	// The size of the palette record is stored in multiples of 4 bytes,
+	// including the size byte.
+	for len(rec)%4 != 0 {
+		rec = append(rec, 0)
+	}
+	rec[0] = uint8(len(rec) / 4)
+	return rec
+}
+
+// huffmanCode is the code of a value in a Huffman tree, where the first bit of
+// the code is stored in the least significant bit of bits.
+type huffmanCode struct {
+	// Code bits.
+	bits uint32
+	// Code length in bits.
+	n uint
+}
+
+// write writes the code to the bitstream.
+func (c huffmanCode) write(bw *bitWriter) {
+	bw.WriteBits(c.bits, c.n)
+}
+
+// writeHuffmanTree writes a balanced 16-bit Huffman tree of the given sorted
+// values to the bitstream, and returns the code of each value. The tree is
+// absent if no values are given.
+//
+// The escape values of the tree are chosen from values not present in the
+// tree, so that no leaf of the tree holds recently decoded values.
+func writeHuffmanTree(bw *bitWriter, vals []uint32) (map[uint32]huffmanCode, error) {
+	if len(vals) == 0 {
+		bw.WriteBit(0)
+		return nil, nil
+	}
+	var escapes []uint32
+	for val, i := uint32(0), 0; val <= 0xFFFF && len(escapes) < 3; val++ {
+		if i < len(vals) && vals[i] == val {
+			i++
+			continue
+		}
+		escapes = append(escapes, val)
+	}
+	if len(escapes) < 3 {
+		return nil, errors.Errorf("unable to choose escape values; %d distinct values present", len(vals))
+	}
+	bw.WriteBit(1)
+	// Low and high byte trees.
+	var los, his []uint32
+	seenLo := make(map[uint32]bool)
+	seenHi := make(map[uint32]bool)
+	for _, val := range vals {
+		if lo := val & 0xFF; !seenLo[lo] {
+			seenLo[lo] = true
+			los = append(los, lo)
+		}
+		if hi := val >> 8; !seenHi[hi] {
+			seenHi[hi] = true
+			his = append(his, hi)
+		}
+	}
+	sort.Slice(his, func(i, j int) bool { return his[i] < his[j] })
+	sort.Slice(los, func(i, j int) bool { return los[i] < los[j] })
+	loCodes := writeByteTree(bw, los)
+	hiCodes := writeByteTree(bw, his)
+	for _, escape := range escapes {
+		bw.WriteBits(escape, 16)
+	}
+	codes := make(map[uint32]huffmanCode)
+	writeTreeNode(bw, vals, huffmanCode{}, codes, func(val uint32) {
+		loCodes[val&0xFF].write(bw)
+		hiCodes[val>>8].write(bw)
+	})
+	// Terminating bit of the tree.
+	bw.WriteBit(0)
+	return codes, nil
+}
+
+// writeByteTree writes a balanced 8-bit Huffman tree of the given sorted values
+// to the bitstream, and returns the code of each value.
+func writeByteTree(bw *bitWriter, vals []uint32) map[uint32]huffmanCode {
+	bw.WriteBit(1)
+	codes := make(map[uint32]huffmanCode)
+	writeTreeNode(bw, vals, huffmanCode{}, codes, func(val uint32) {
+		bw.WriteBits(val, 8)
+	})
+	// Terminating bit of the tree.
+	bw.WriteBit(0)
+	return codes
+}
+
+// writeTreeNode writes a balanced subtree of the given values with the code
+// prefix to the bitstream, recording the code of each value in codes. The leaf
+// function writes the value of leaf nodes.
+func writeTreeNode(bw *bitWriter, vals []uint32, prefix huffmanCode, codes map[uint32]huffmanCode, leaf func(val uint32)) {
+	if len(vals) == 1 {
+		// Leaf node.
+		bw.WriteBit(0)
+		leaf(vals[0])
+		codes[vals[0]] = prefix
+		return
+	}
+	// Branch node; the left subtree (bit 0) is followed by the right subtree
+	// (bit 1).
+	bw.WriteBit(1)
+	mid := len(vals) / 2
+	writeTreeNode(bw, vals[:mid], huffmanCode{bits: prefix.bits, n: prefix.n + 1}, codes, leaf)
+	writeTreeNode(bw, vals[mid:], huffmanCode{bits: prefix.bits | 1<<prefix.n, n: prefix.n + 1}, codes, leaf)
+}
+
+// treeAllocSize returns the allocation size in bytes of a 16-bit Huffman tree
+// with the given number of leaves, including the three escape leaves.
+func treeAllocSize(nleaves int) int {
+	if nleaves == 0 {
+		return 0
+	}
+	return 4 * (2*nleaves - 1 + 3)
+}
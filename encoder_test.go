@@ -0,0 +1,128 @@
+package smk
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// randomFrames returns n random frames of the given dimensions, using ncolors
+// distinct palette indices; every other frame retains the palette of the
+// preceding frame.
+func randomFrames(rnd *rand.Rand, n, width, height, ncolors int) []*image.Paletted {
+	var imgs []*image.Paletted
+	for i := 0; i < n; i++ {
+		pal := make(color.Palette, 256)
+		for j := range pal {
+			pal[j] = color.RGBA{R: expand6(uint8(rnd.Intn(64))), G: expand6(uint8(rnd.Intn(64))), B: expand6(uint8(rnd.Intn(64))), A: 0xFF}
+		}
+		if i%2 == 1 {
+			pal = imgs[i-1].Palette
+		}
+		img := image.NewPaletted(image.Rect(0, 0, width, height), pal)
+		for j := range img.Pix {
+			img.Pix[j] = uint8(rnd.Intn(ncolors))
+		}
+		imgs = append(imgs, img)
+	}
+	return imgs
+}
+
+// checkFrame checks the pixels and palette of the i-th decoded frame.
+func checkFrame(t *testing.T, i int, got, want *image.Paletted) {
+	t.Helper()
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("frame %d: pixel mismatch", i)
+	}
+	if !reflect.DeepEqual(got.Palette, want.Palette) {
+		t.Errorf("frame %d: palette mismatch", i)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, ncolors := range []int{1, 2, 7, 256} {
+		imgs := randomFrames(rnd, 5, 16, 12, ncolors)
+		raw := encodeFrames(t, imgs, 40)
+		// Sequential decoding.
+		f := parseBytes(t, raw)
+		if err := f.Validate(); err != nil {
+			t.Fatalf("%d colours: invalid Smacker file; %+v", ncolors, err)
+		}
+		if f.Width != 16 || f.Height != 12 || f.NFrames != 5 || f.FrameRate != 40 {
+			t.Errorf("%d colours: header mismatch; expected 16x12 with 5 frames at rate 40, got %dx%d with %d frames at rate %d", ncolors, f.Width, f.Height, f.NFrames, f.FrameRate)
+		}
+		for i, want := range imgs {
+			img, err := f.DecodeFrame(i)
+			if err != nil {
+				t.Fatalf("%d colours: frame %d: unable to decode frame; %+v", ncolors, i, err)
+			}
+			checkFrame(t, i, img, want)
+		}
+		// Random access decoding, in reverse order.
+		f = parseBytesAt(t, raw)
+		for i := len(imgs) - 1; i >= 0; i-- {
+			img, err := f.DecodeFrame(i)
+			if err != nil {
+				t.Fatalf("%d colours: frame %d: unable to decode frame; %+v", ncolors, i, err)
+			}
+			checkFrame(t, i, img, imgs[i])
+		}
+		all, err := f.DecodeAllParallel(3)
+		if err != nil {
+			t.Fatalf("%d colours: unable to decode frames; %+v", ncolors, err)
+		}
+		for i, img := range all {
+			checkFrame(t, i, img, imgs[i])
+		}
+	}
+}
+
+func TestEncoderShortPalette(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.RGBA{R: 0xFF, A: 0xFF}, color.RGBA{B: 0xFF, A: 0xFF}})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 2)
+	}
+	got, err := parseBytes(t, encodeFrames(t, []*image.Paletted{img}, 100)).DecodeFrame(0)
+	if err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	if !bytes.Equal(got.Pix, img.Pix) {
+		t.Error("pixel mismatch")
+	}
+	// Entries beyond the palette are black.
+	want := append(color.Palette{}, img.Palette...)
+	for len(want) < 256 {
+		want = append(want, color.RGBA{A: 0xFF})
+	}
+	if !reflect.DeepEqual(got.Palette, want) {
+		t.Errorf("palette mismatch; expected %v, got %v", want[:3], got.Palette[:3])
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 8, 4), testPalette(0))
+	e := NewEncoder(&bytes.Buffer{}, 8, 4, 2, 100)
+	if err := e.WriteFrame(image.NewPaletted(image.Rect(0, 0, 4, 4), testPalette(0))); err == nil {
+		t.Error("expected error for mismatched frame dimensions")
+	}
+	if err := e.WriteFrame(img); err != nil {
+		t.Fatalf("unable to write frame; %+v", err)
+	}
+	if err := e.Close(); err == nil {
+		t.Error("expected error for fewer frames than specified")
+	}
+	if err := e.WriteFrame(img); err != nil {
+		t.Fatalf("unable to write frame; %+v", err)
+	}
+	if err := e.WriteFrame(img); err == nil {
+		t.Error("expected error for more frames than specified")
+	}
+	e = NewEncoder(&bytes.Buffer{}, 6, 4, 1, 100)
+	if err := e.WriteFrame(image.NewPaletted(image.Rect(0, 0, 6, 4), testPalette(0))); err == nil {
+		t.Error("expected error for frame width not a multiple of 4")
+	}
+}
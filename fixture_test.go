@@ -0,0 +1,183 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"sort"
+	"testing"
+
+	"github.com/lunixbochs/struc"
+)
+
+// absentTrees is the Huffman tree data of a Smacker file with all four trees
+// absent; every block is then a mono block of palette index 0.
+var absentTrees = []byte{0}
+
+// testFrame is a frame of a Smacker file assembled by buildFile.
+type testFrame struct {
+	// Frame type.
+	typ FrameType
+	// Key frame.
+	key bool
+	// Frame data, padded to a multiple of 4 bytes by buildFile.
+	data []byte
+}
+
+// buildFile assembles a Smacker file from the given header, Huffman tree data
+// and frames. The signature and dimensions default to "SMK2" and 4x4 if unset,
+// and the frame count, tables and trees size are derived from the frames and
+// tree data; the last frame is the ring frame if FlagRingFrame is set.
+func buildFile(t testing.TB, hdr FileHeader, trees []byte, frames []testFrame) []byte {
+	t.Helper()
+	if hdr.Signature == "" {
+		hdr.Signature = "SMK2"
+	}
+	if hdr.Width == 0 && hdr.Height == 0 {
+		hdr.Width, hdr.Height = 4, 4
+	}
+	hdr.NFrames = len(frames)
+	if hdr.Flags.HasRingFrame() {
+		hdr.NFrames--
+	}
+	hdr.TreesSize = len(trees)
+	buf := &bytes.Buffer{}
+	if err := struc.Pack(buf, &hdr); err != nil {
+		t.Fatalf("unable to pack file header; %v", err)
+	}
+	var data [][]byte
+	for _, frame := range frames {
+		d := append([]byte{}, frame.data...)
+		for len(d)%4 != 0 {
+			d = append(d, 0)
+		}
+		size := uint32(len(d))
+		if frame.key {
+			size |= 1
+		}
+		binary.Write(buf, binary.LittleEndian, size)
+		data = append(data, d)
+	}
+	for _, frame := range frames {
+		buf.WriteByte(byte(frame.typ))
+	}
+	buf.Write(trees)
+	for _, d := range data {
+		buf.Write(d)
+	}
+	return buf.Bytes()
+}
+
+// parseBytes parses the given Smacker file.
+func parseBytes(t testing.TB, raw []byte) *File {
+	t.Helper()
+	f, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("unable to parse Smacker file; %+v", err)
+	}
+	return f
+}
+
+// parseBytesAt parses the given Smacker file for random access.
+func parseBytesAt(t testing.TB, raw []byte) *File {
+	t.Helper()
+	f, err := ParseReaderAt(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatalf("unable to parse Smacker file; %+v", err)
+	}
+	return f
+}
+
+// testPalette returns a palette of 256 distinct colours representable with 6
+// bits per component, offset by the given value.
+func testPalette(off int) color.Palette {
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		c := uint8(i + off)
+		pal[i] = color.RGBA{R: expand6(c & 0x3F), G: expand6(c >> 2), B: expand6(63 - c&0x3F), A: 0xFF}
+	}
+	return pal
+}
+
+// testFrames returns n distinct frames of the given dimensions, where every
+// third frame changes the palette.
+func testFrames(n, width, height int) []*image.Paletted {
+	var imgs []*image.Paletted
+	for i := 0; i < n; i++ {
+		img := image.NewPaletted(image.Rect(0, 0, width, height), testPalette(i/3))
+		for j := range img.Pix {
+			img.Pix[j] = uint8(j*7 + i*13 + j/width*3)
+		}
+		imgs = append(imgs, img)
+	}
+	return imgs
+}
+
+// encodeFrames encodes the given frames as a Smacker file, using the Encoder.
+func encodeFrames(t testing.TB, imgs []*image.Paletted, rate FrameRate) []byte {
+	t.Helper()
+	bounds := imgs[0].Bounds()
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf, bounds.Dx(), bounds.Dy(), len(imgs), rate)
+	for _, img := range imgs {
+		if err := enc.WriteFrame(img); err != nil {
+			t.Fatalf("unable to write frame; %+v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to encode Smacker file; %+v", err)
+	}
+	return buf.Bytes()
+}
+
+// testTrees holds the Huffman tree data of a Smacker file for tests, and the
+// code of each value of the trees.
+type testTrees struct {
+	// Huffman tree data.
+	data []byte
+	// Allocation sizes of the mono blocks maps, mono blocks colours, full
+	// blocks and block type descriptors trees.
+	sizes [4]int
+	// Codes of the mono blocks maps, mono blocks colours, full blocks and block
+	// type descriptors trees.
+	mmap, mclr, full, typ map[uint32]huffmanCode
+}
+
+// newTestTrees returns the Huffman trees of the given values, as written by the
+// Encoder; trees without values are absent.
+func newTestTrees(t testing.TB, mmap, mclr, full, typ []uint32) *testTrees {
+	t.Helper()
+	tt := &testTrees{}
+	bw := &bitWriter{}
+	codes := make([]map[uint32]huffmanCode, 4)
+	for i, vals := range [][]uint32{mmap, mclr, full, typ} {
+		vals = append([]uint32{}, vals...)
+		sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+		var err error
+		if codes[i], err = writeHuffmanTree(bw, vals); err != nil {
+			t.Fatalf("unable to write Huffman tree; %+v", err)
+		}
+		tt.sizes[i] = treeAllocSize(len(codes[i]))
+	}
+	tt.data = bw.Bytes()
+	tt.mmap, tt.mclr, tt.full, tt.typ = codes[0], codes[1], codes[2], codes[3]
+	return tt
+}
+
+// header returns a file header with the allocation sizes of the trees.
+func (tt *testTrees) header() FileHeader {
+	return FileHeader{
+		MMapSize: tt.sizes[0],
+		MClrSize: tt.sizes[1],
+		FullSize: tt.sizes[2],
+		TypeSize: tt.sizes[3],
+	}
+}
+
+// writeFullRow writes the codes of a row of a full block to the bitstream,
+// right half first.
+func (tt *testTrees) writeFullRow(bw *bitWriter, row []uint8) {
+	tt.full[uint32(row[2])|uint32(row[3])<<8].write(bw)
+	tt.full[uint32(row[0])|uint32(row[1])<<8].write(bw)
+}
@@ -0,0 +1,611 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeFrame decodes and returns the i-th video frame of the Smacker file.
+//
+// Frames are decoded sequentially, as each frame is stored as a delta of its
+// preceding frame. Frames between the most recently decoded frame and frame i
+// are decoded as needed; an error is returned if frame i precedes the next
+// frame to be decoded.
+//
+// Files parsed using ParseReaderAt support random access; decoding then starts
+// at the most recent key frame preceding frame i if needed.
+func (f *File) DecodeFrame(i int) (*image.Paletted, error) {
+	if err := f.decodeFrameAt(i); err != nil {
+		return nil, err
+	}
+	return f.image(), nil
+}
+
+// DecodeFrameRGBA decodes and returns the i-th video frame of the Smacker file
+// as an RGBA image, as for DecodeFrame; each pixel is converted using the
+// palette in effect at frame i.
+func (f *File) DecodeFrameRGBA(i int) (*image.RGBA, error) {
+	if err := f.decodeFrameAt(i); err != nil {
+		return nil, err
+	}
+	bounds := f.displayBounds()
+	pix := make([]uint8, bounds.Dx()*bounds.Dy())
+	f.render(pix)
+	img := image.NewRGBA(bounds)
+	for j, index := range pix {
+		c := color.RGBAModel.Convert(f.pal[index]).(color.RGBA)
+		img.Pix[4*j], img.Pix[4*j+1], img.Pix[4*j+2], img.Pix[4*j+3] = c.R, c.G, c.B, c.A
+	}
+	return img, nil
+}
+
+// DecodeFrameInto decodes the i-th video frame of the Smacker file into dst, as
+// for DecodeFrame. The bounds of dst must match the dimensions of displayed
+// frames, and the palette of dst is overwritten by the palette of the frame;
+// thus, no memory is allocated when dst is reused between calls, unless a
+// palette record changes the colour of a palette entry.
+func (f *File) DecodeFrameInto(i int, dst *image.Paletted) error {
+	bounds := f.displayBounds()
+	if dst.Rect != bounds || dst.Stride != bounds.Dx() {
+		return errors.Errorf("invalid destination image; expected bounds %v with stride %d, got bounds %v with stride %d", bounds, bounds.Dx(), dst.Rect, dst.Stride)
+	}
+	if err := f.decodeFrameAt(i); err != nil {
+		return err
+	}
+	f.render(dst.Pix)
+	dst.Palette = append(dst.Palette[:0], f.pal...)
+	return nil
+}
+
+// DecodeFrameBytes decodes and returns the i-th video frame of the Smacker file
+// from the given frame data, as stored on disk; e.g. as returned by RawFrame.
+// No frame data is read from the underlying reader, and the length of data must
+// match FrameLen(i). The decoder state is updated as for DecodeFrame, so that
+// frame i+1 is the next frame to decode.
+//
+// As each frame is a delta of its preceding frame, frame i must be the next
+// frame to decode. Files parsed without random access skip the frame data of r
+// when frames following frame i are decoded from r.
+func (f *File) DecodeFrameBytes(i int, data []byte) (*image.Paletted, error) {
+	if i < 0 || i >= f.NFrames {
+		return nil, errors.Errorf("invalid frame index; expected 0 <= i < %d, got %d", f.NFrames, i)
+	}
+	if i != f.cur {
+		return nil, errors.Errorf("unable to decode frame %d out of sequence; next frame to decode is %d", i, f.cur)
+	}
+	if len(data) != f.FrameLen(i) {
+		return nil, errors.Errorf("invalid frame data length of frame %d; expected %d, got %d", i, f.FrameLen(i), len(data))
+	}
+	// Retain a copy of the frame data, as the audio data refers to it.
+	f.buf = append(f.buf[:0], data...)
+	if err := f.decodeFrameData(f.buf, f.FrameTypes[i]); err != nil {
+		return nil, errors.WithMessagef(err, "unable to decode frame %d", i)
+	}
+	if f.ra == nil {
+		f.skip += int64(len(data))
+	}
+	f.cur++
+	return f.image(), nil
+}
+
+// Thumbnail decodes and returns the first video frame of the Smacker file, which
+// is always a key frame. A grayscale palette is used if the first frame has no
+// palette record.
+//
+// Files parsed using ParseReaderAt decode the thumbnail independently, leaving
+// the decoder state unaffected. Otherwise, the first frame is decoded as part
+// of sequential decoding, as for DecodeFrame.
+func (f *File) Thumbnail() (*image.Paletted, error) {
+	g := f
+	if f.ra != nil {
+		g = f.cloneDecoder()
+	}
+	img, err := g.DecodeFrame(0)
+	if err != nil {
+		return nil, err
+	}
+	if f.FrameTypes[0]&FrameTypePaletteRecord == 0 {
+		img.Palette = grayPalette()
+	}
+	return img, nil
+}
+
+// decodeFrameAt decodes the frames up to and including the i-th frame into the
+// frame buffer, seeking to the most recent key frame of random access files.
+func (f *File) decodeFrameAt(i int) error {
+	if i < 0 || i >= f.NFrames {
+		return errors.Errorf("invalid frame index; expected 0 <= i < %d, got %d", f.NFrames, i)
+	}
+	if f.err != nil {
+		return f.err
+	}
+	if f.ra != nil {
+		if err := f.seekKeyFrame(i); err != nil {
+			return err
+		}
+	} else if i < f.cur {
+		return errors.Errorf("unable to decode frame %d out of sequence; next frame to decode is %d", i, f.cur)
+	}
+	return f.decodeFrames(i)
+}
+
+// Seek positions the decoder at the i-th frame, so that frame i is the next
+// frame to decode; e.g. by the next step of a frame iterator. The preceding
+// frames starting at the most recent key frame are decoded, to reconstruct the
+// frame that frame i is a delta of; the first frame is always a key frame.
+//
+// Files parsed using ParseReaderAt support seeking in any direction. Otherwise,
+// an error is returned if frame i precedes the next frame to decode.
+func (f *File) Seek(i int) error {
+	if i < 0 || i >= f.NFrames {
+		return errors.Errorf("invalid frame index; expected 0 <= i < %d, got %d", f.NFrames, i)
+	}
+	if f.ra != nil {
+		if err := f.seekKeyFrame(i); err != nil {
+			return err
+		}
+	} else if i < f.cur {
+		return errors.Errorf("unable to seek to frame %d out of sequence; next frame to decode is %d", i, f.cur)
+	}
+	return f.decodeFrames(i - 1)
+}
+
+// CurrentFrame returns the index of the most recently decoded frame, or -1 if
+// no frame has been decoded since parsing or the most recent seek to the first
+// frame. The next frame to decode, e.g. by a frame iterator, is thus
+// CurrentFrame()+1.
+func (f *File) CurrentFrame() int {
+	return f.cur - 1
+}
+
+// decodeFrames decodes the frames from the next frame to decode up to and
+// including the i-th frame.
+func (f *File) decodeFrames(i int) error {
+	for f.cur <= i {
+		if err := f.decodeNextFrame(); err != nil {
+			return errors.WithMessagef(err, "unable to decode frame %d", f.cur)
+		}
+	}
+	return nil
+}
+
+// seekKeyFrame positions the decoder of a random access file for decoding of
+// the i-th frame. Decoding restarts at the most recent key frame preceding or
+// at frame i, unless frame i may be reached by decoding forward from the next
+// frame to decode without passing a key frame.
+//
+// The palette is a delta of the palette of the preceding frame, so the palette
+// records of the frames preceding the key frame are applied to retain the
+// palette in effect.
+func (f *File) seekKeyFrame(i int) error {
+	key := i
+	for key > 0 && !f.IsKeyFrame(key) {
+		key--
+	}
+	if i >= f.cur && key <= f.cur {
+		// Decode forward from the next frame to decode.
+		return nil
+	}
+	f.initFrameBuffers()
+	start := f.cur
+	if key < f.cur {
+		// Restart from the initial palette.
+		start = 0
+		f.resetPalette()
+	}
+	for j := start; j < key; j++ {
+		if err := f.applyPaletteRecord(j); err != nil {
+			return errors.WithMessagef(err, "unable to decode palette record of frame %d", j)
+		}
+	}
+	for j := range f.frame {
+		f.frame[j] = 0
+	}
+	f.cur = key
+	return nil
+}
+
+// applyPaletteRecord reads and applies the palette record of the i-th frame of
+// a random access file, if present.
+func (f *File) applyPaletteRecord(i int) error {
+	if f.FrameTypes[i]&FrameTypePaletteRecord == 0 {
+		return nil
+	}
+	var size [1]byte
+	if err := f.readAt(size[:], f.offsets[i]); err != nil {
+		return err
+	}
+	// The size of the palette record is stored in multiples of 4 bytes,
+	// including the size byte.
+	n := 4 * int(size[0])
+	if n == 0 || n > f.FrameLen(i) {
+		return errors.Errorf("invalid palette record size; expected 0 < size <= %d, got %d", f.FrameLen(i), n)
+	}
+	data := make([]byte, n)
+	if err := f.readAt(data, f.offsets[i]); err != nil {
+		return err
+	}
+	return f.decodePalette(data[1:])
+}
+
+// RawFrame returns a copy of the i-th frame of the Smacker file as stored on
+// disk, including its palette record and audio data; the ring frame, if
+// present, is at index NFrames.
+//
+// Files parsed using ParseReaderAt read the frame directly. Otherwise, frames
+// are read as part of sequential decoding, as for DecodeAudio; frames between
+// the most recently decoded frame and frame i are decoded as needed.
+func (f *File) RawFrame(i int) ([]byte, error) {
+	if err := f.checkRawFrame(i); err != nil {
+		return nil, err
+	}
+	if f.ra != nil {
+		buf := make([]byte, f.FrameLen(i))
+		if err := f.readAt(buf, f.offsets[i]); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+	// The frame data buffer grows as data is read, so that frame sizes of
+	// corrupt files do not cause excessive allocations.
+	data, err := f.sequentialFrame(i)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// ReadFrameInto reads the i-th frame of the Smacker file as stored on disk into
+// buf, as for RawFrame. The buffer is reset rather than appended to, so that no
+// memory is allocated when buf is reused between calls, unless the frame is
+// larger than its capacity. Together with DecodeFrameBytes, this allows
+// decoding with full control over the allocation of frame data.
+func (f *File) ReadFrameInto(i int, buf *bytes.Buffer) error {
+	buf.Reset()
+	if err := f.checkRawFrame(i); err != nil {
+		return err
+	}
+	if f.ra != nil {
+		// Read the frame into the unused capacity of buf.
+		n := f.FrameLen(i)
+		buf.Grow(n)
+		data := buf.Bytes()[:n]
+		if err := f.readAt(data, f.offsets[i]); err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+	data, err := f.sequentialFrame(i)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// checkRawFrame checks that the i-th frame, including the ring frame, is within
+// range and within the file size if known.
+func (f *File) checkRawFrame(i int) error {
+	if i < 0 || i >= len(f.FrameSizes) {
+		return errors.Errorf("invalid frame index; expected 0 <= i < %d, got %d", len(f.FrameSizes), i)
+	}
+	if f.size != -1 && f.offsets[i+1] > f.size {
+		return errors.Wrapf(ErrTruncatedFrameData, "frame %d ends at offset %d, beyond file size %d", i, f.offsets[i+1], f.size)
+	}
+	return nil
+}
+
+// sequentialFrame decodes the frames up to and including the i-th frame of a
+// file without random access, and returns the frame data of frame i as stored
+// on disk; a slice of the frame data buffer.
+func (f *File) sequentialFrame(i int) ([]byte, error) {
+	if i != f.cur-1 {
+		if i < f.cur {
+			return nil, errors.Errorf("unable to read frame %d out of sequence; most recently decoded frame is %d", i, f.cur-1)
+		}
+		if err := f.decodeFrames(i); err != nil {
+			return nil, err
+		}
+	}
+	return f.buf, nil
+}
+
+// HasRingFrame reports whether the Smacker file has a ring frame; i.e. an extra
+// frame following the last frame, which holds the first frame stored as a
+// delta of the last frame for seamless looping.
+func (f *File) HasRingFrame() bool {
+	return f.Flags.HasRingFrame()
+}
+
+// IsKeyFrame reports whether the i-th frame is a key frame. It returns false if
+// i is out of range.
+func (f *File) IsKeyFrame(i int) bool {
+	if i < 0 || i >= len(f.FrameSizes) {
+		return false
+	}
+	// Bit 0 determines if the frame is a key frame.
+	return f.FrameSizes[i]&1 != 0
+}
+
+// FrameLen returns the size in bytes of the i-th frame. It returns 0 if i is
+// out of range.
+func (f *File) FrameLen(i int) int {
+	if i < 0 || i >= len(f.FrameSizes) {
+		return 0
+	}
+	// Clear bit 0 and 1 to get the proper frame size.
+	return f.FrameSizes[i] &^ 3
+}
+
+// FrameOffset returns the offset in bytes of the i-th frame from the start of
+// the Smacker file, or -1 if i is out of range.
+func (f *File) FrameOffset(i int) int64 {
+	if i < 0 || i >= len(f.offsets)-1 {
+		return -1
+	}
+	return f.offsets[i]
+}
+
+// FrameInfo describes a frame of a Smacker file, as derived from the frame size
+// and type tables.
+type FrameInfo struct {
+	// Key frame.
+	KeyFrame bool
+	// Size of the frame in bytes.
+	Size int
+	// Offset of the frame in bytes from the start of the Smacker file.
+	Offset int64
+	// Frame contains a palette record.
+	HasPalette bool
+	// Tracks for which the frame contains audio data, in increasing order.
+	AudioTracks []int
+}
+
+// FrameInfo returns information about the i-th frame, without decoding the
+// frame. The zero value is returned if i is out of range.
+func (f *File) FrameInfo(i int) FrameInfo {
+	if i < 0 || i >= len(f.FrameTypes) {
+		return FrameInfo{}
+	}
+	typ := f.FrameTypes[i]
+	info := FrameInfo{
+		KeyFrame:   f.IsKeyFrame(i),
+		Size:       f.FrameLen(i),
+		Offset:     f.FrameOffset(i),
+		HasPalette: typ&FrameTypePaletteRecord != 0,
+	}
+	for track := 0; track < len(f.TrackInfo); track++ {
+		if typ&(FrameTypeAudioDataTrack0<<uint(track)) != 0 {
+			info.AudioTracks = append(info.AudioTracks, track)
+		}
+	}
+	return info
+}
+
+// indexFrames records the offset of each frame. The frame data directly
+// follows the file header, the frame size and type tables, and the Huffman
+// trees.
+func (f *File) indexFrames() {
+	f.offsets = make([]int64, len(f.FrameSizes)+1)
+	f.offsets[0] = headerSize + 5*int64(len(f.FrameSizes)) + int64(f.TreesSize)
+	for i := range f.FrameSizes {
+		f.offsets[i+1] = f.offsets[i] + int64(f.FrameLen(i))
+	}
+}
+
+// readAt reads len(buf) bytes starting at offset off of the random access file
+// into buf.
+func (f *File) readAt(buf []byte, off int64) error {
+	// ReadAt may return io.EOF when reading up to the end of the file, and
+	// returns a non-nil error otherwise if fewer than len(buf) bytes are read.
+	if n, err := f.ra.ReadAt(buf, off); n < len(buf) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeNextFrame reads and decodes the next frame of the Smacker file.
+func (f *File) decodeNextFrame() error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.size != -1 && f.offsets[f.cur+1] > f.size {
+		return errors.Wrapf(ErrTruncatedFrameData, "frame data ends at offset %d, beyond file size %d", f.offsets[f.cur+1], f.size)
+	}
+	if err := f.readNextFrame(); err != nil {
+		if isEOF(err) {
+			return errors.Wrapf(ErrTruncatedFrameData, "unable to read %d bytes of frame data at offset %d", f.FrameLen(f.cur), f.offsets[f.cur])
+		}
+		return err
+	}
+	if err := f.decodeFrameData(f.buf, f.FrameTypes[f.cur]); err != nil {
+		return err
+	}
+	f.cur++
+	return nil
+}
+
+// readNextFrame reads the frame data of the next frame into the frame data
+// buffer.
+func (f *File) readNextFrame() error {
+	if f.skip > 0 {
+		// Skip the frame data of frames decoded by DecodeFrameBytes.
+		if _, err := io.CopyN(io.Discard, f.r, f.skip); err != nil {
+			return errors.WithStack(err)
+		}
+		f.skip = 0
+	}
+	size := f.FrameLen(f.cur)
+	switch {
+	case f.ra != nil:
+		if cap(f.buf) < size {
+			f.buf = make([]byte, size)
+		}
+		f.buf = f.buf[:size]
+		return f.readAt(f.buf, f.offsets[f.cur])
+	case cap(f.buf) < size:
+		// The size of the file may be unknown, so grow the buffer as data is
+		// read.
+		buf, err := readN(f.r, int64(size))
+		if err != nil {
+			return err
+		}
+		f.buf = buf
+	default:
+		f.buf = f.buf[:size]
+		if _, err := io.ReadFull(f.r, f.buf); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeFrameData decodes the given frame data, based on the frame type.
+func (f *File) decodeFrameData(data []byte, typ FrameType) error {
+	f.initFrameBuffers()
+	pal, audio, video, err := splitFrameData(data, typ)
+	if err != nil {
+		return err
+	}
+	// Palette record.
+	f.palChanged = false
+	if pal != nil {
+		if err := f.decodePalette(pal); err != nil {
+			return err
+		}
+		f.palChanged = !palEqual(f.pal, f.prevPal)
+	}
+	// Audio data.
+	f.audio = audio
+	// Video data.
+	return f.decodeVideo(video)
+}
+
+// splitFrameData splits the given frame data into the palette record (excluding
+// the size byte), the audio data of each track and the video data, based on the
+// frame type. The palette record and audio data are nil if not present.
+//
+// The frame data consists of an optional palette record, followed by audio
+// data for each track present in the frame type, followed by video data.
+//
+// The frame type is authoritative for the presence of audio data, even for
+// tracks which the track information of the file header reports as absent; the
+// track information only describes the audio format.
+func splitFrameData(data []byte, typ FrameType) (pal []byte, audio [7][]byte, video []byte, err error) {
+	// Palette record.
+	if typ&FrameTypePaletteRecord != 0 {
+		if len(data) < 1 {
+			return nil, audio, nil, errors.New("invalid palette record; missing size")
+		}
+		// The size of the palette record is stored in multiples of 4 bytes,
+		// including the size byte.
+		size := 4 * int(data[0])
+		if size == 0 || size > len(data) {
+			return nil, audio, nil, errors.Errorf("invalid palette record size; expected 0 < size <= %d, got %d", len(data), size)
+		}
+		pal = data[1:size]
+		data = data[size:]
+	}
+	// Audio data.
+	for track := range audio {
+		if typ&(FrameTypeAudioDataTrack0<<uint(track)) == 0 {
+			continue
+		}
+		if len(data) < 4 {
+			return nil, audio, nil, errors.Errorf("invalid audio data of track %d; missing size", track)
+		}
+		// The size of the audio data includes the 4-byte size.
+		size := int(binary.LittleEndian.Uint32(data))
+		if size < 4 || size > len(data) {
+			return nil, audio, nil, errors.Errorf("invalid audio data size of track %d; expected 4 <= size <= %d, got %d", track, len(data), size)
+		}
+		audio[track] = data[4:size]
+		data = data[size:]
+	}
+	return pal, audio, data, nil
+}
+
+// initFrameBuffers initializes the frame buffer and the palette, if not already
+// initialized. The initial frame is zero and the initial palette is black. The
+// buffers of a previously parsed file are reused if their capacity allows.
+func (f *File) initFrameBuffers() {
+	n := f.Width * f.Height
+	if len(f.frame) == n {
+		return
+	}
+	if cap(f.frame) < n {
+		f.frame = make([]uint8, n)
+	} else {
+		f.frame = f.frame[:n]
+		for i := range f.frame {
+			f.frame[i] = 0
+		}
+	}
+	if cap(f.pal) < 256 {
+		f.pal = make(color.Palette, 256)
+	}
+	f.pal = f.pal[:256]
+	f.resetPalette()
+}
+
+// black is the colour of each entry of the initial palette.
+var black color.Color = color.RGBA{A: 0xFF}
+
+// resetPalette resets the palette to black.
+func (f *File) resetPalette() {
+	for i := range f.pal {
+		f.pal[i] = black
+	}
+}
+
+// image returns a copy of the current frame buffer as a paletted image, using
+// the current palette.
+func (f *File) image() *image.Paletted {
+	pal := make(color.Palette, len(f.pal))
+	copy(pal, f.pal)
+	img := image.NewPaletted(f.displayBounds(), pal)
+	f.render(img.Pix)
+	return img
+}
+
+// displayBounds returns the bounds of displayed frames. The height of frames
+// is doubled for Y-interlaced and Y-doubled videos.
+func (f *File) displayBounds() image.Rectangle {
+	if f.Flags.IsYInterlaced() || f.Flags.IsYDoubled() {
+		return image.Rect(0, 0, f.Width, 2*f.Height)
+	}
+	return image.Rect(0, 0, f.Width, f.Height)
+}
+
+// render renders the current frame buffer into the pixels of a displayed frame,
+// with bounds as specified by displayBounds.
+//
+// Each row of the frame buffer is displayed twice in Y-doubled videos, and on
+// every other row in Y-interlaced videos, leaving the odd rows at palette index
+// 0.
+func (f *File) render(pix []uint8) {
+	switch {
+	case f.Flags.IsYDoubled():
+		for y := 0; y < f.Height; y++ {
+			row := f.frame[y*f.Width : (y+1)*f.Width]
+			copy(pix[2*y*f.Width:], row)
+			copy(pix[(2*y+1)*f.Width:], row)
+		}
+	case f.Flags.IsYInterlaced():
+		for y := 0; y < f.Height; y++ {
+			copy(pix[2*y*f.Width:], f.frame[y*f.Width:(y+1)*f.Width])
+			odd := pix[(2*y+1)*f.Width : (2*y+2)*f.Width]
+			for x := range odd {
+				odd[x] = 0
+			}
+		}
+	default:
+		copy(pix, f.frame)
+	}
+}
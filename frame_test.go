@@ -0,0 +1,615 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// deltaFile returns an 8x4 Smacker file of two frames, where frame 0 is a key
+// frame of two full blocks and frame 1 is a delta frame of a void block
+// followed by a solid block of palette index 9, and the pixels of both frames.
+func deltaFile(t testing.TB) (raw []byte, frames [2][]uint8) {
+	t.Helper()
+	pix := []uint8{
+		1, 2, 3, 4, 5, 6, 7, 8,
+		9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24,
+		25, 26, 27, 28, 29, 30, 31, 32,
+	}
+	var full []uint32
+	for i := 0; i < len(pix); i += 2 {
+		full = append(full, uint32(pix[i])|uint32(pix[i+1])<<8)
+	}
+	const (
+		fullRun2 = blockFull | 1<<2
+		void     = blockVoid
+		solid9   = blockSolid | 9<<8
+	)
+	tt := newTestTrees(t, nil, nil, full, []uint32{fullRun2, void, solid9})
+	// Frame 0.
+	bw := &bitWriter{}
+	tt.typ[fullRun2].write(bw)
+	for x := 0; x < 8; x += 4 {
+		for y := 0; y < 4; y++ {
+			tt.writeFullRow(bw, pix[y*8+x:y*8+x+4])
+		}
+	}
+	data0 := bw.Bytes()
+	// Frame 1.
+	bw = &bitWriter{}
+	tt.typ[void].write(bw)
+	tt.typ[solid9].write(bw)
+	data1 := bw.Bytes()
+	hdr := tt.header()
+	hdr.Width, hdr.Height = 8, 4
+	raw = buildFile(t, hdr, tt.data, []testFrame{
+		{key: true, data: data0},
+		{data: data1},
+	})
+	frames[0] = pix
+	frames[1] = append([]uint8{}, pix...)
+	for y := 0; y < 4; y++ {
+		copy(frames[1][y*8+4:y*8+8], []uint8{9, 9, 9, 9})
+	}
+	return raw, frames
+}
+
+func TestDecodeFrame(t *testing.T) {
+	raw, frames := deltaFile(t)
+	f := parseBytes(t, raw)
+	for i, want := range frames {
+		img, err := f.DecodeFrame(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		if img.Rect.Dx() != 8 || img.Rect.Dy() != 4 {
+			t.Errorf("frame %d: expected 8x4 image, got %v", i, img.Rect)
+		}
+		if !bytes.Equal(img.Pix, want) {
+			t.Errorf("frame %d: pixel mismatch; expected %v, got %v", i, want, img.Pix)
+		}
+		if len(img.Palette) != 256 {
+			t.Errorf("frame %d: expected 256 palette entries, got %d", i, len(img.Palette))
+		}
+	}
+}
+
+func TestDecodeFrameApplyPreceding(t *testing.T) {
+	raw, frames := deltaFile(t)
+	f := parseBytes(t, raw)
+	// Frame 0 is decoded internally before the delta frame.
+	img, err := f.DecodeFrame(1)
+	if err != nil {
+		t.Fatalf("unable to decode frame 1; %+v", err)
+	}
+	if !bytes.Equal(img.Pix, frames[1]) {
+		t.Errorf("pixel mismatch; expected %v, got %v", frames[1], img.Pix)
+	}
+	// Frames of sequential sources cannot be decoded out of sequence.
+	if _, err := f.DecodeFrame(0); err == nil {
+		t.Error("expected error for out of sequence frame 0")
+	}
+	if _, err := f.DecodeFrame(2); err == nil {
+		t.Error("expected error for frame index out of range")
+	}
+}
+
+func TestFrameOffset(t *testing.T) {
+	frames := []testFrame{
+		{key: true, data: make([]byte, 12)},
+		{data: make([]byte, 4)},
+		{data: nil},
+		{key: true, data: make([]byte, 20)},
+	}
+	raw := buildFile(t, FileHeader{}, absentTrees, frames)
+	// Set bit 1 of the second frame size, which is not part of the length.
+	raw[headerSize+4] |= 2
+	f := parseBytes(t, raw)
+	start := int64(headerSize + 5*len(frames) + len(absentTrees))
+	if got := f.FrameOffset(0); got != start {
+		t.Errorf("frame 0 offset mismatch; expected %d, got %d", start, got)
+	}
+	off := start
+	for i, frame := range frames {
+		if got := f.FrameOffset(i); got != off {
+			t.Errorf("frame %d offset mismatch; expected %d, got %d", i, off, got)
+		}
+		off += int64(len(frame.data))
+	}
+	last := len(frames) - 1
+	if end := f.FrameOffset(last) + int64(f.FrameLen(last)); end != int64(len(raw)) {
+		t.Errorf("end of frame data mismatch; expected %d, got %d", len(raw), end)
+	}
+	for _, i := range []int{-1, len(frames)} {
+		if got := f.FrameOffset(i); got != -1 {
+			t.Errorf("frame %d offset; expected -1 for out of range index, got %d", i, got)
+		}
+	}
+}
+
+func TestFrameSizeLowBits(t *testing.T) {
+	f := &File{FileHeader: FileHeader{
+		NFrames:    4,
+		FrameSizes: []int{0x100, 0x101, 0x102, 0x103},
+	}}
+	golden := []struct {
+		key bool
+		n   int
+	}{
+		{key: false, n: 0x100},
+		{key: true, n: 0x100},
+		{key: false, n: 0x100},
+		{key: true, n: 0x100},
+	}
+	for i, g := range golden {
+		if got := f.IsKeyFrame(i); got != g.key {
+			t.Errorf("frame %d: key frame mismatch; expected %v, got %v", i, g.key, got)
+		}
+		if got := f.FrameLen(i); got != g.n {
+			t.Errorf("frame %d: length mismatch; expected 0x%X, got 0x%X", i, g.n, got)
+		}
+	}
+	for _, i := range []int{-1, 4} {
+		if f.IsKeyFrame(i) {
+			t.Errorf("frame %d: expected non-key frame for out of range index", i)
+		}
+		if got := f.FrameLen(i); got != 0 {
+			t.Errorf("frame %d: expected zero length for out of range index, got %d", i, got)
+		}
+	}
+}
+
+func TestDecodeFrameYScaling(t *testing.T) {
+	rows := [][]uint8{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+		{13, 14, 15, 16},
+	}
+	var full []uint32
+	for _, row := range rows {
+		full = append(full, uint32(row[0])|uint32(row[1])<<8, uint32(row[2])|uint32(row[3])<<8)
+	}
+	tt := newTestTrees(t, nil, nil, full, []uint32{blockFull})
+	bw := &bitWriter{}
+	tt.typ[blockFull].write(bw)
+	for _, row := range rows {
+		tt.writeFullRow(bw, row)
+	}
+	zero := []uint8{0, 0, 0, 0}
+	golden := []struct {
+		flags Flag
+		want  [][]uint8
+	}{
+		{flags: 0, want: rows},
+		{flags: FlagYDoubled, want: [][]uint8{rows[0], rows[0], rows[1], rows[1], rows[2], rows[2], rows[3], rows[3]}},
+		{flags: FlagYInterlaced, want: [][]uint8{rows[0], zero, rows[1], zero, rows[2], zero, rows[3], zero}},
+	}
+	for _, g := range golden {
+		hdr := tt.header()
+		hdr.Flags = g.flags
+		f := parseBytes(t, buildFile(t, hdr, tt.data, []testFrame{{key: true, data: bw.Bytes()}}))
+		img, err := f.DecodeFrame(0)
+		if err != nil {
+			t.Fatalf("flags 0x%X: unable to decode frame; %+v", g.flags, err)
+		}
+		if img.Rect.Dx() != 4 || img.Rect.Dy() != len(g.want) {
+			t.Errorf("flags 0x%X: expected 4x%d image, got %v", g.flags, len(g.want), img.Rect)
+			continue
+		}
+		for y, want := range g.want {
+			if got := img.Pix[y*img.Stride : y*img.Stride+4]; !bytes.Equal(got, want) {
+				t.Errorf("flags 0x%X: row %d mismatch; expected %v, got %v", g.flags, y, want, got)
+			}
+		}
+	}
+}
+
+func TestFrameInfo(t *testing.T) {
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testMono16
+	hdr.TrackInfo[3] = testMono16
+	frames := []testFrame{
+		{key: true, typ: FrameTypePaletteRecord | FrameTypeAudioDataTrack0 | FrameTypeAudioDataTrack3, data: make([]byte, 16)},
+		{data: make([]byte, 8)},
+	}
+	f := parseBytes(t, buildFile(t, hdr, absentTrees, frames))
+	start := int64(headerSize + 5*len(frames) + len(absentTrees))
+	golden := []FrameInfo{
+		{KeyFrame: true, Size: 16, Offset: start, HasPalette: true, AudioTracks: []int{0, 3}},
+		{KeyFrame: false, Size: 8, Offset: start + 16},
+	}
+	for i, want := range golden {
+		if got := f.FrameInfo(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("frame %d: frame information mismatch; expected %+v, got %+v", i, want, got)
+		}
+	}
+	if got := f.FrameInfo(len(frames)); !reflect.DeepEqual(got, FrameInfo{}) {
+		t.Errorf("expected zero value for out of range index, got %+v", got)
+	}
+	// No frame data is read.
+	if f.cur != 0 {
+		t.Errorf("expected no frames decoded, next frame is %d", f.cur)
+	}
+}
+
+// samePaletteFrames returns n distinct frames of the given dimensions, which
+// share the same palette.
+func samePaletteFrames(n, width, height int) []*image.Paletted {
+	imgs := testFrames(n, width, height)
+	for _, img := range imgs {
+		img.Palette = imgs[0].Palette
+	}
+	return imgs
+}
+
+func TestDecodeFrameIntoAllocs(t *testing.T) {
+	imgs := samePaletteFrames(8, 64, 64)
+	f := parseBytesAt(t, encodeFrames(t, imgs, 100))
+	dst := image.NewPaletted(image.Rect(0, 0, 64, 64), nil)
+	if err := f.DecodeFrameInto(0, dst); err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	i := 1
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := f.DecodeFrameInto(i%len(imgs), dst); err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i%len(imgs), err)
+		}
+		i++
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per decoded frame, got %v", allocs)
+	}
+	want := imgs[(i-1)%len(imgs)]
+	if !bytes.Equal(dst.Pix, want.Pix) {
+		t.Error("pixel mismatch of most recently decoded frame")
+	}
+	if err := f.DecodeFrameInto(0, image.NewPaletted(image.Rect(0, 0, 32, 32), nil)); err == nil {
+		t.Error("expected error for destination image of mismatched bounds")
+	}
+}
+
+func BenchmarkDecodeFrameInto(b *testing.B) {
+	imgs := samePaletteFrames(8, 320, 200)
+	f := parseBytesAt(b, encodeFrames(b, imgs, 100))
+	dst := image.NewPaletted(image.Rect(0, 0, 320, 200), nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := f.DecodeFrameInto(i%len(imgs), dst); err != nil {
+			b.Fatalf("frame %d: unable to decode frame; %+v", i%len(imgs), err)
+		}
+	}
+}
+
+func BenchmarkDecodeFrame(b *testing.B) {
+	imgs := samePaletteFrames(8, 320, 200)
+	raw := encodeFrames(b, imgs, 100)
+	for _, table := range []bool{true, false} {
+		name := "table"
+		if !table {
+			name = "walk"
+		}
+		b.Run(name, func(b *testing.B) {
+			f := parseBytesAt(b, raw)
+			if !table {
+				for _, tree := range []*HuffmanTree{f.mmapTree, f.mclrTree, f.fullTree, f.typeTree} {
+					tree.table = nil
+				}
+			}
+			dst := image.NewPaletted(image.Rect(0, 0, 320, 200), nil)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := f.DecodeFrameInto(i%len(imgs), dst); err != nil {
+					b.Fatalf("frame %d: unable to decode frame; %+v", i%len(imgs), err)
+				}
+			}
+		})
+	}
+}
+
+func TestRawFrame(t *testing.T) {
+	raw, _ := loopFile(t, true)
+	for _, ra := range []bool{true, false} {
+		f := parseBytes(t, raw)
+		if ra {
+			f = parseBytesAt(t, raw)
+		}
+		// Frames including the ring frame.
+		var data []byte
+		for i := 0; i <= f.NFrames; i++ {
+			frame, err := f.RawFrame(i)
+			if err != nil {
+				t.Fatalf("random access %v: frame %d: unable to read raw frame; %+v", ra, i, err)
+			}
+			if len(frame) != f.FrameLen(i) {
+				t.Errorf("random access %v: frame %d: size mismatch; expected %d, got %d", ra, i, f.FrameLen(i), len(frame))
+			}
+			data = append(data, frame...)
+		}
+		if want := raw[f.FrameOffset(0):]; !bytes.Equal(data, want) {
+			t.Errorf("random access %v: frame data mismatch; expected %v, got %v", ra, want, data)
+		}
+		if _, err := f.RawFrame(f.NFrames + 1); err == nil {
+			t.Errorf("random access %v: expected error for invalid frame index", ra)
+		}
+		// Only random access files may read frames out of sequence.
+		_, err := f.RawFrame(0)
+		switch {
+		case ra && err != nil:
+			t.Errorf("random access %v: unable to read raw frame 0 anew; %+v", ra, err)
+		case !ra && err == nil:
+			t.Errorf("random access %v: expected error for frame read out of sequence", ra)
+		}
+	}
+}
+
+func TestDecodeFrameBytes(t *testing.T) {
+	// The palette changes at frame 3.
+	imgs := testFrames(6, 8, 4)
+	raw := encodeFrames(t, imgs, 100)
+	src := parseBytesAt(t, raw)
+	var frames [][]byte
+	for i := range imgs {
+		data, err := src.RawFrame(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to read frame; %+v", i, err)
+		}
+		frames = append(frames, data)
+	}
+	for _, ra := range []bool{true, false} {
+		f := parseBytesAt(t, raw)
+		if !ra {
+			f = parseBytes(t, raw)
+		}
+		// Without random access, the frames following those decoded from frame
+		// data are decoded from the underlying reader.
+		for i, want := range imgs {
+			var img *image.Paletted
+			var err error
+			if ra || i < 3 {
+				img, err = f.DecodeFrameBytes(i, frames[i])
+			} else {
+				img, err = f.DecodeFrame(i)
+			}
+			if err != nil {
+				t.Fatalf("ra=%v: frame %d: unable to decode frame; %+v", ra, i, err)
+			}
+			if !bytes.Equal(img.Pix, want.Pix) {
+				t.Errorf("ra=%v: frame %d: pixel mismatch", ra, i)
+			}
+			if !reflect.DeepEqual(img.Palette, want.Palette) {
+				t.Errorf("ra=%v: frame %d: palette mismatch", ra, i)
+			}
+		}
+	}
+	f := parseBytesAt(t, raw)
+	if _, err := f.DecodeFrameBytes(0, frames[0][:len(frames[0])-4]); err == nil {
+		t.Error("expected error for frame data of mismatched length")
+	}
+	if _, err := f.DecodeFrameBytes(1, frames[1]); err == nil {
+		t.Error("expected error for decoding frame out of sequence")
+	}
+	if _, err := f.DecodeFrameBytes(len(imgs), frames[0]); err == nil {
+		t.Error("expected error for invalid frame index")
+	}
+}
+
+func TestRawFrameTruncated(t *testing.T) {
+	raw, _ := loopFile(t, true)
+	// Inflate the size of frame 1 far beyond the file size.
+	raw = append([]byte{}, raw...)
+	binary.LittleEndian.PutUint32(raw[headerSize+4:], 0x7FFFFFFC)
+	sequential, err := Parse(readerOnly{bytes.NewReader(raw)})
+	if err != nil {
+		t.Fatalf("unable to parse Smacker file; %+v", err)
+	}
+	files := map[string]*File{
+		"random access": parseBytesAt(t, raw),
+		"seekable":      parseBytes(t, raw),
+		"sequential":    sequential,
+	}
+	for name, f := range files {
+		if _, err := f.RawFrame(0); err != nil {
+			t.Errorf("%s: unable to read frame 0; %+v", name, err)
+		}
+		if _, err := f.RawFrame(1); !errors.Is(err, ErrTruncatedFrameData) {
+			t.Errorf("%s: expected ErrTruncatedFrameData, got %v", name, err)
+		}
+		if err := f.ReadFrameInto(1, &bytes.Buffer{}); !errors.Is(err, ErrTruncatedFrameData) {
+			t.Errorf("%s: expected ErrTruncatedFrameData, got %v", name, err)
+		}
+	}
+}
+
+func TestReadFrameInto(t *testing.T) {
+	raw, _ := loopFile(t, true)
+	for _, ra := range []bool{true, false} {
+		f := parseBytes(t, raw)
+		if ra {
+			f = parseBytesAt(t, raw)
+		}
+		// The buffer is reset rather than appended to.
+		buf := bytes.NewBufferString("stale")
+		for i := 0; i <= f.NFrames; i++ {
+			if err := f.ReadFrameInto(i, buf); err != nil {
+				t.Fatalf("random access %v: frame %d: unable to read frame; %+v", ra, i, err)
+			}
+			off := f.FrameOffset(i)
+			if want := raw[off : off+int64(f.FrameLen(i))]; !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("random access %v: frame %d: frame data mismatch; expected %v, got %v", ra, i, want, buf.Bytes())
+			}
+		}
+		if err := f.ReadFrameInto(-1, buf); err == nil {
+			t.Errorf("random access %v: expected error for invalid frame index", ra)
+		}
+	}
+	// Reading into a reused buffer and decoding from its bytes does not
+	// allocate frame data.
+	imgs := samePaletteFrames(8, 64, 64)
+	f := parseBytesAt(t, encodeFrames(t, imgs, 100))
+	buf := &bytes.Buffer{}
+	for i, want := range imgs {
+		if err := f.ReadFrameInto(i, buf); err != nil {
+			t.Fatalf("frame %d: unable to read frame; %+v", i, err)
+		}
+		img, err := f.DecodeFrameBytes(i, buf.Bytes())
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		if !bytes.Equal(img.Pix, want.Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := f.ReadFrameInto(len(imgs)-1, buf); err != nil {
+			t.Fatalf("unable to read frame; %+v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per read frame, got %v", allocs)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	raw, colors := loopFile(t, false)
+	f := parseBytesAt(t, raw)
+	last, err := f.DecodeFrame(1)
+	if err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	img, err := f.Thumbnail()
+	if err != nil {
+		t.Fatalf("unable to decode thumbnail; %+v", err)
+	}
+	if got := img.Palette[0]; got != colors[0] {
+		t.Errorf("palette entry 0 mismatch; expected %v, got %v", colors[0], got)
+	}
+	// The decoder state of random access files is unaffected.
+	if f.cur != 2 {
+		t.Errorf("expected next frame to decode to remain 2, got %d", f.cur)
+	}
+	if got := f.Palette()[0]; got != last.Palette[0] {
+		t.Errorf("current palette entry 0 mismatch; expected %v, got %v", last.Palette[0], got)
+	}
+
+	// A grayscale palette is used if the first frame has no palette record.
+	f = parseBytes(t, buildFile(t, FileHeader{}, absentTrees, []testFrame{{key: true}}))
+	if img, err = f.Thumbnail(); err != nil {
+		t.Fatalf("unable to decode thumbnail; %+v", err)
+	}
+	for _, i := range []int{0, 255} {
+		if got, want := img.Palette[i], (color.Gray{Y: uint8(i)}); got != want {
+			t.Errorf("palette entry %d mismatch; expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	// Each palette record copies entry 1 of the preceding palette to entry 0,
+	// and sets entry 1 to a colour unique to the frame; the palette thus
+	// depends on the preceding frame, even for the key frame 5.
+	var frames []testFrame
+	for i := byte(0); i < 10; i++ {
+		rec := palRecord(0x40, 1, i, 2*i, 3*i, 0x80|127, 0x80|125)
+		frames = append(frames, testFrame{key: i == 0 || i == 5, typ: FrameTypePaletteRecord, data: rec})
+	}
+	raw := buildFile(t, FileHeader{}, absentTrees, frames)
+	for _, i := range []int{0, 4, 5, 7, 2} {
+		want, err := parseBytes(t, raw).DecodeFrame(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		f := parseBytesAt(t, raw)
+		if _, err := f.DecodeFrame(9); err != nil {
+			t.Fatalf("unable to decode frame; %+v", err)
+		}
+		if err := f.Seek(i); err != nil {
+			t.Fatalf("frame %d: unable to seek; %+v", i, err)
+		}
+		if f.cur != i {
+			t.Errorf("frame %d: expected next frame to decode %d, got %d", i, i, f.cur)
+		}
+		it := f.Frames()
+		if !it.Next() {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, it.Err())
+		}
+		for j := 0; j < 2; j++ {
+			if got := it.Frame().Palette[j]; got != want.Palette[j] {
+				t.Errorf("frame %d: palette entry %d mismatch; expected %v, got %v", i, j, want.Palette[j], got)
+			}
+		}
+	}
+	// Files without random access only seek forward.
+	f := parseBytes(t, raw)
+	if err := f.Seek(4); err != nil {
+		t.Fatalf("unable to seek; %+v", err)
+	}
+	if err := f.Seek(2); err == nil {
+		t.Error("expected error for seeking backward without random access")
+	}
+	if err := f.Seek(10); err == nil {
+		t.Error("expected error for invalid frame index")
+	}
+}
+
+func TestCurrentFrame(t *testing.T) {
+	raw, _ := loopFile(t, true)
+	f := parseBytesAt(t, raw)
+	check := func(op string, want int) {
+		t.Helper()
+		if got := f.CurrentFrame(); got != want {
+			t.Errorf("%s: current frame mismatch; expected %d, got %d", op, want, got)
+		}
+	}
+	check("parse", -1)
+	if _, err := f.DecodeFrame(1); err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	check("DecodeFrame(1)", 1)
+	if err := f.Seek(1); err != nil {
+		t.Fatalf("unable to seek; %+v", err)
+	}
+	check("Seek(1)", 0)
+	// Looping past the last frame decodes the ring frame as frame 0.
+	it := f.LoopFrames()
+	for j := 0; j < 2; j++ {
+		if !it.Next() {
+			t.Fatalf("unable to decode frame; %+v", it.Err())
+		}
+	}
+	check("LoopFrames", 0)
+	if err := f.Seek(0); err != nil {
+		t.Fatalf("unable to seek; %+v", err)
+	}
+	check("Seek(0)", -1)
+}
+
+func TestDecodeFrameRGBA(t *testing.T) {
+	// The palette changes at frame 3.
+	imgs := testFrames(4, 8, 4)
+	f := parseBytesAt(t, encodeFrames(t, imgs, 100))
+	for i := len(imgs) - 1; i >= 0; i-- {
+		img, err := f.DecodeFrameRGBA(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		src := imgs[i]
+		if img.Bounds() != src.Bounds() {
+			t.Fatalf("frame %d: bounds mismatch; expected %v, got %v", i, src.Bounds(), img.Bounds())
+		}
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 8; x++ {
+				if got, want := img.RGBAAt(x, y), src.At(x, y); got != want {
+					t.Fatalf("frame %d: pixel (%d, %d) mismatch; expected %v, got %v", i, x, y, want, got)
+				}
+			}
+		}
+	}
+}
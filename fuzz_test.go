@@ -0,0 +1,47 @@
+package smk
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add(encodeFrames(f, testFrames(2, 8, 8), 100))
+	raw, _ := deltaFile(f)
+	f.Add(raw)
+	hdr := FileHeader{Signature: "SMK4", Flags: FlagYDoubled}
+	hdr.TrackInfo[0] = testMono16
+	hdr.AudioSize[0] = 20
+	samples := []int{1000, -3, 200, -32768, 32767, 5, 5, 5, -1, 1234}
+	f.Add(buildFile(f, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypePaletteRecord | FrameTypeAudioDataTrack0, data: append(palRecord(63, 0, 32, 0x80|127, 0x80|127), audioChunk(encodeAudio(samples, 1, true))...)},
+	}))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		file, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		if err := file.Validate(); err != nil {
+			return
+		}
+		// Skip large frames, as the decoding time is proportional to the frame
+		// dimensions rather than the input size.
+		if file.Width*file.Height > 256*256 {
+			return
+		}
+		file.DecodeFrame(0)
+		for track := range file.TrackInfo {
+			file.DecodeAudio(track, 0)
+		}
+		file, err = ParseReaderAt(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("unable to parse for random access; %+v", err)
+		}
+		for i := 0; i < file.NFrames && i < 4; i++ {
+			file.DecodeFrame(i)
+			for track := range file.TrackInfo {
+				file.DecodeAudio(track, i)
+			}
+		}
+	})
+}
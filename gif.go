@@ -0,0 +1,95 @@
+package smk
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WriteGIF decodes every video frame of the Smacker file, and writes them to w
+// as an animated GIF image.
+func (f *File) WriteGIF(w io.Writer) error {
+	g, err := f.ToGIF()
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(w, g); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ToGIF decodes every video frame of the Smacker file, and returns them as an
+// animated GIF image, without encoding it.
+//
+// The palette of the first frame is used as the global colour table, and a
+// local colour table is emitted for frames whose palette differs. GIF frame
+// delays are specified in hundredths of a second, so the delay of each frame is
+// derived from the rounded presentation times of consecutive frames; thus
+// rounding errors do not accumulate and the total delay stays within 5 ms of
+// Duration. Frames are scaled horizontally if the pixel aspect ratio is not
+// 1:1, as specified by SetPixelAspectRatio.
+func (f *File) ToGIF() (*gif.GIF, error) {
+	if f.NFrames <= 0 {
+		return nil, errors.New("unable to encode GIF image; no frames present")
+	}
+	g := &gif.GIF{
+		Image: make([]*image.Paletted, 0, f.NFrames),
+		Delay: make([]int, 0, f.NFrames),
+	}
+	var pal color.Palette
+	for i := 0; i < f.NFrames; i++ {
+		img, err := f.DecodeFrame(i)
+		if err != nil {
+			return nil, err
+		}
+		// Share the palette of unchanged frames, to use the same colour table.
+		if i > 0 && !f.PaletteChanged() {
+			img.Palette = pal
+		}
+		pal = img.Palette
+		if num, den := f.PixelAspectRatio(); num != den {
+			img = scaleX(img, num, den)
+		}
+		g.Image = append(g.Image, img)
+		delay := centis(f.FrameTimestamp(i+1)) - centis(f.FrameTimestamp(i))
+		g.Delay = append(g.Delay, delay)
+	}
+	bounds := g.Image[0].Rect
+	g.Config = image.Config{
+		ColorModel: g.Image[0].Palette,
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+	}
+	return g, nil
+}
+
+// scaleX returns a copy of src scaled horizontally by num/den, using
+// nearest-neighbour sampling of the source pixel under the centre of each
+// destination pixel. The scaled width is rounded to the nearest integer, and is
+// at least 1.
+func scaleX(src *image.Paletted, num, den int) *image.Paletted {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw := (2*w*num + den) / (2 * den)
+	if dw < 1 {
+		dw = 1
+	}
+	dst := image.NewPaletted(image.Rect(0, 0, dw, h), src.Palette)
+	for x := 0; x < dw; x++ {
+		sx := (2*x + 1) * w / (2 * dw)
+		for y := 0; y < h; y++ {
+			dst.Pix[y*dst.Stride+x] = src.Pix[y*src.Stride+sx]
+		}
+	}
+	return dst
+}
+
+// centis returns the given duration in hundredths of a second, rounded to the
+// nearest integer.
+func centis(d time.Duration) int {
+	return int((d + 5*time.Millisecond) / (10 * time.Millisecond))
+}
@@ -0,0 +1,132 @@
+package smk
+
+import (
+	"bytes"
+	"flag"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestWriteGIF(t *testing.T) {
+	// 15 fps; frame delays of 7 and 6 hundredths of a second alternate.
+	imgs := testFrames(6, 8, 8)
+	f := parseBytes(t, encodeFrames(t, imgs, -6667))
+	buf := &bytes.Buffer{}
+	if err := f.WriteGIF(buf); err != nil {
+		t.Fatalf("unable to write GIF image; %+v", err)
+	}
+	golden := filepath.Join("testdata", "frames.gif")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("GIF image mismatch with %q", golden)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unable to decode GIF image; %v", err)
+	}
+	if len(anim.Image) != len(imgs) {
+		t.Fatalf("expected %d frames, got %d", len(imgs), len(anim.Image))
+	}
+	total := 0
+	for i, img := range anim.Image {
+		if !bytes.Equal(img.Pix, imgs[i].Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+		for j, c := range img.Palette {
+			if r, g, b, _ := c.RGBA(); [3]uint32{r, g, b} != rgb(imgs[i].Palette[j].RGBA()) {
+				t.Errorf("frame %d: palette entry %d mismatch", i, j)
+				break
+			}
+		}
+		total += anim.Delay[i]
+	}
+	if want := centis(f.Duration()); total != want {
+		t.Errorf("total delay mismatch; expected %d, got %d", want, total)
+	}
+}
+
+// rgb returns the colour components of the given alpha-premultiplied colour.
+func rgb(r, g, b, _ uint32) [3]uint32 {
+	return [3]uint32{r, g, b}
+}
+
+func TestToGIF(t *testing.T) {
+	imgs := testFrames(6, 8, 8)
+	raw := encodeFrames(t, imgs, -6667)
+	g, err := parseBytes(t, raw).ToGIF()
+	if err != nil {
+		t.Fatalf("unable to convert to GIF image; %+v", err)
+	}
+	if len(g.Image) != len(imgs) || len(g.Delay) != len(imgs) {
+		t.Fatalf("expected %d frames and delays, got %d and %d", len(imgs), len(g.Image), len(g.Delay))
+	}
+	for i, img := range g.Image {
+		if !bytes.Equal(img.Pix, imgs[i].Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+	}
+	if g.Config.Width != 8 || g.Config.Height != 8 {
+		t.Errorf("dimensions mismatch; expected 8x8, got %dx%d", g.Config.Width, g.Config.Height)
+	}
+	// WriteGIF encodes the same GIF image as is returned by ToGIF.
+	got := &bytes.Buffer{}
+	if err := gif.EncodeAll(got, g); err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	if err := parseBytes(t, raw).WriteGIF(want); err != nil {
+		t.Fatalf("unable to write GIF image; %+v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("GIF image mismatch between ToGIF and WriteGIF")
+	}
+}
+
+func TestToGIFPixelAspectRatio(t *testing.T) {
+	imgs := testFrames(2, 4, 4)
+	golden := []struct {
+		num, den int
+		// Source column of each destination column.
+		cols []int
+	}{
+		{num: 2, den: 1, cols: []int{0, 0, 1, 1, 2, 2, 3, 3}},
+		{num: 1, den: 2, cols: []int{1, 3}},
+		// 4*5/6 = 3.33 rounds to 3 columns.
+		{num: 5, den: 6, cols: []int{0, 2, 3}},
+	}
+	for _, g := range golden {
+		f := parseBytes(t, encodeFrames(t, imgs, 100))
+		f.SetPixelAspectRatio(g.num, g.den)
+		anim, err := f.ToGIF()
+		if err != nil {
+			t.Fatalf("%d:%d: unable to convert to GIF image; %+v", g.num, g.den, err)
+		}
+		if anim.Config.Width != len(g.cols) || anim.Config.Height != 4 {
+			t.Errorf("%d:%d: dimensions mismatch; expected %dx4, got %dx%d", g.num, g.den, len(g.cols), anim.Config.Width, anim.Config.Height)
+		}
+		for i, img := range anim.Image {
+			if img.Rect.Dx() != len(g.cols) || img.Rect.Dy() != 4 {
+				t.Fatalf("%d:%d: frame %d: bounds mismatch; got %v", g.num, g.den, i, img.Rect)
+			}
+			for y := 0; y < 4; y++ {
+				for x, sx := range g.cols {
+					if got, want := img.ColorIndexAt(x, y), imgs[i].ColorIndexAt(sx, y); got != want {
+						t.Errorf("%d:%d: frame %d: pixel (%d,%d) mismatch; expected %d, got %d", g.num, g.den, i, x, y, want, got)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package smk
 
 import (
+	"encoding/binary"
+
 	"github.com/lunixbochs/struc"
 	"github.com/pkg/errors"
 )
 
+// headerSize is the size in bytes of the fixed-size part of the file header,
+// preceding the frame size and type tables.
+const headerSize = 104
+
 // parseFileHeader parses the file header of the Smacker file.
 func (f *File) parseFileHeader() error {
 	if err := struc.Unpack(f.r, &f.FileHeader); err != nil {
@@ -17,9 +23,67 @@ func (f *File) parseFileHeader() error {
 	default:
 		return errors.Errorf(`invalid Smacker signature; got %q, want "SMK2" or "SMK4"`, f.Signature)
 	}
+	// Verify frame dimensions.
+	if f.Width == 0 || f.Height == 0 || f.Width > maxDimension || f.Height > maxDimension {
+		return errors.Wrapf(ErrInvalidDimensions, "expected 1 <= width, height <= %d, got %dx%d", maxDimension, f.Width, f.Height)
+	}
+	// Parse frame size and type tables, which include the ring frame if
+	// present. The tables are read incrementally, so that a corrupt frame count
+	// does not cause excessive allocations.
+	n := f.NFrames
+	if f.Flags.HasRingFrame() {
+		n++
+	}
+	sizes, err := readN(f.r, 4*int64(n))
+	if err != nil {
+		return errors.WithMessage(err, "unable to read frame size table")
+	}
+	f.FrameSizes = make([]int, n)
+	for i := range f.FrameSizes {
+		f.FrameSizes[i] = int(binary.LittleEndian.Uint32(sizes[4*i:]))
+	}
+	types, err := readN(f.r, int64(n))
+	if err != nil {
+		return errors.WithMessage(err, "unable to read frame type table")
+	}
+	f.FrameTypes = make([]FrameType, n)
+	for i, typ := range types {
+		f.FrameTypes[i] = FrameType(typ)
+	}
 	return nil
 }
 
+// Version returns the version of the Smacker file format, as specified by the
+// signature; i.e. 2 for "SMK2" and 4 for "SMK4".
+func (f *File) Version() int {
+	if f.Signature == "SMK4" {
+		return 4
+	}
+	return 2
+}
+
+// KeyFrameIntervals returns the number of frames between consecutive key
+// frames, where the last interval extends to the end of the file. A file with
+// a single key frame at the start has one interval of NFrames, and a file
+// without key frames has no intervals.
+func (f *File) KeyFrameIntervals() []int {
+	var intervals []int
+	prev := -1
+	for i := 0; i < f.NFrames; i++ {
+		if !f.IsKeyFrame(i) {
+			continue
+		}
+		if prev != -1 {
+			intervals = append(intervals, i-prev)
+		}
+		prev = i
+	}
+	if prev != -1 {
+		intervals = append(intervals, f.NFrames-prev)
+	}
+	return intervals
+}
+
 // FileHeader is a general file description header.
 type FileHeader struct {
 	// File signature; "SMK2" or "SMK4".
@@ -33,7 +97,7 @@ type FileHeader struct {
 	// Frame rate.
 	FrameRate FrameRate `struc:"int32,little"`
 	// Video flags.
-	Flags Flag
+	Flags Flag `struc:"uint32,little"`
 	// Size of the largest unpacked audio data buffer in bytes; one per track.
 	AudioSize [7]int `struc:"[7]uint32,little"`
 	// Total size in bytes of Huffman trees stored in file.
@@ -47,16 +111,17 @@ type FileHeader struct {
 	// Allocation size for the block type descriptors Huffman tree.
 	TypeSize int `struc:"uint32,little"`
 	// Frequency and format information for each sound track; one per track.
-	// TODO: Verify if little or big endian encoding.
+	// Stored little-endian, as all other fields of the header.
 	TrackInfo [7]TrackInfo `struc:"[7]uint32,little"`
-	// Unused.
-	_ uint32
-	// Frame size in number of bytes. Bit 0 determines if the frame is a key
-	// frame. The purpose of bit 1 is unknown. Note, to get the proper length,
-	// clear bit 0 and 1.
-	FrameSizes []int `struc:"[]uint32,little,sizefrom=NFrames"`
-	// Frame types.
-	FrameTypes []FrameType `struc:"sizefrom=NFrames"`
+	// Unused. Note, struc skips blank fields, so the field must be exported to
+	// be read.
+	Unused uint32 `struc:"uint32,little"`
+	// Frame size in number of bytes; one per frame, including the ring frame
+	// if present. Bit 0 determines if the frame is a key frame. The purpose of
+	// bit 1 is unknown. Note, to get the proper length, clear bit 0 and 1.
+	FrameSizes []int `struc:"skip"`
+	// Frame types; one per frame, including the ring frame if present.
+	FrameTypes []FrameType `struc:"skip"`
 }
 
 // FrameRate specifies the number of frames per second.
@@ -88,7 +153,32 @@ func (rate FrameRate) FPS() float64 {
 type Flag uint32
 
 // Video flags.
-const ()
+const (
+	// The file contains a ring frame, following the last frame, for looping
+	// playback.
+	FlagRingFrame Flag = 1 << iota
+	// Y-interlaced; each decoded row is displayed on every other row of an
+	// image of twice the height.
+	FlagYInterlaced
+	// Y-doubled; each decoded row is displayed twice, in an image of twice the
+	// height.
+	FlagYDoubled
+)
+
+// HasRingFrame reports whether the file contains a ring frame.
+func (fl Flag) HasRingFrame() bool {
+	return fl&FlagRingFrame != 0
+}
+
+// IsYInterlaced reports whether the video is Y-interlaced.
+func (fl Flag) IsYInterlaced() bool {
+	return fl&FlagYInterlaced != 0
+}
+
+// IsYDoubled reports whether the video is Y-doubled.
+func (fl Flag) IsYDoubled() bool {
+	return fl&FlagYDoubled != 0
+}
 
 // TrackInfo describes the frequency and format information of a sound track.
 //
@@ -0,0 +1,142 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTrackInfo(t *testing.T) {
+	raw := buildFile(t, FileHeader{}, absentTrees, []testFrame{{key: true}})
+	// The track information table starts at offset 72 of the header, and is
+	// stored little-endian; set track 1 to compressed 16-bit stereo audio at
+	// 22050 Hz.
+	binary.LittleEndian.PutUint32(raw[72+4:], 0xF0000000|22050)
+	f := parseBytes(t, raw)
+	info := f.TrackInfo[1]
+	if got := info.SampleRate(); got != 22050 {
+		t.Errorf("sample rate mismatch; expected 22050, got %d", got)
+	}
+	if got := info.BitRate(); got != 16 {
+		t.Errorf("bit rate mismatch; expected 16, got %d", got)
+	}
+	if got := info.NChannels(); got != 2 {
+		t.Errorf("number of channels mismatch; expected 2, got %d", got)
+	}
+	if !info.HasAudioData() {
+		t.Error("expected audio data present")
+	}
+	if !info.IsCompressed() {
+		t.Error("expected compressed audio data")
+	}
+	if !info.IsVersion2() {
+		t.Error("expected v2 sound compression")
+	}
+	for track, info := range f.TrackInfo {
+		if track != 1 && info != 0 {
+			t.Errorf("track %d: expected zero track information, got 0x%08X", track, uint32(info))
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	for _, g := range []struct {
+		sig  string
+		want int
+	}{
+		{sig: "SMK2", want: 2},
+		{sig: "SMK4", want: 4},
+	} {
+		f := parseBytes(t, buildFile(t, FileHeader{Signature: g.sig}, absentTrees, []testFrame{{key: true}}))
+		if got := f.Version(); got != g.want {
+			t.Errorf("signature %q: version mismatch; expected %d, got %d", g.sig, g.want, got)
+		}
+	}
+	raw := buildFile(t, FileHeader{Signature: "SMK3"}, absentTrees, []testFrame{{key: true}})
+	if _, err := Parse(bytes.NewReader(raw)); err == nil {
+		t.Error("expected error for invalid signature")
+	}
+}
+
+func TestKeyFrameIntervals(t *testing.T) {
+	golden := []struct {
+		keys  []bool
+		flags Flag
+		want  []int
+	}{
+		{keys: []bool{true, true, true}, want: []int{1, 1, 1}},
+		{keys: []bool{true, false, false, false}, want: []int{4}},
+		{keys: []bool{true, false, true, false, false}, want: []int{2, 3}},
+		// Frames preceding the first key frame are not part of an interval.
+		{keys: []bool{false, true, false}, want: []int{2}},
+		// The ring frame is not part of an interval.
+		{keys: []bool{true, false, true}, flags: FlagRingFrame, want: []int{2}},
+		{keys: []bool{false, false}, want: nil},
+	}
+	for _, g := range golden {
+		var frames []testFrame
+		for _, key := range g.keys {
+			frames = append(frames, testFrame{key: key})
+		}
+		f := parseBytes(t, buildFile(t, FileHeader{Flags: g.flags}, absentTrees, frames))
+		if got := f.KeyFrameIntervals(); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("key frames %v: intervals mismatch; expected %v, got %v", g.keys, g.want, got)
+		}
+	}
+	// No frames.
+	if got := (&File{}).KeyFrameIntervals(); got != nil {
+		t.Errorf("expected no intervals without frames, got %v", got)
+	}
+}
+
+func TestParseFileHeaderDimensions(t *testing.T) {
+	golden := []struct {
+		width, height int
+		valid         bool
+	}{
+		{width: 0, height: 4},
+		{width: 4, height: 0},
+		{width: 4097, height: 4},
+		{width: 4, height: 4097},
+		{width: 4096, height: 4096, valid: true},
+		{width: 1, height: 1, valid: true},
+	}
+	for _, g := range golden {
+		hdr := FileHeader{Width: g.width, Height: g.height}
+		_, err := Parse(bytes.NewReader(buildFile(t, hdr, absentTrees, []testFrame{{key: true}})))
+		switch {
+		case g.valid && err != nil:
+			t.Errorf("%dx%d: unable to parse Smacker file; %+v", g.width, g.height, err)
+		case !g.valid && !errors.Is(err, ErrInvalidDimensions):
+			t.Errorf("%dx%d: expected ErrInvalidDimensions, got %v", g.width, g.height, err)
+		}
+	}
+}
+
+func TestFlag(t *testing.T) {
+	golden := []struct {
+		flags                     Flag
+		ring, interlaced, doubled bool
+	}{
+		{flags: 0},
+		{flags: 0x01, ring: true},
+		{flags: 0x02, interlaced: true},
+		{flags: 0x04, doubled: true},
+		{flags: 0x07, ring: true, interlaced: true, doubled: true},
+		// Unknown bits are ignored.
+		{flags: 0xFFFFFFF8},
+	}
+	for _, g := range golden {
+		if got := g.flags.HasRingFrame(); got != g.ring {
+			t.Errorf("flags 0x%08X: ring frame mismatch; expected %v, got %v", uint32(g.flags), g.ring, got)
+		}
+		if got := g.flags.IsYInterlaced(); got != g.interlaced {
+			t.Errorf("flags 0x%08X: Y-interlaced mismatch; expected %v, got %v", uint32(g.flags), g.interlaced, got)
+		}
+		if got := g.flags.IsYDoubled(); got != g.doubled {
+			t.Errorf("flags 0x%08X: Y-doubled mismatch; expected %v, got %v", uint32(g.flags), g.doubled, got)
+		}
+	}
+}
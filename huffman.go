@@ -0,0 +1,400 @@
+package smk
+
+import (
+	"github.com/pkg/errors"
+)
+
+// parseHuffmanTrees parses the Huffman trees of the Smacker file.
+func (f *File) parseHuffmanTrees() error {
+	trees, err := readN(f.r, int64(f.TreesSize))
+	if err != nil {
+		if isEOF(err) {
+			return errors.Wrapf(ErrTruncatedTrees, "unable to read %d bytes of Huffman trees", f.TreesSize)
+		}
+		return errors.WithMessage(err, "unable to read Huffman trees")
+	}
+	f.trees = trees
+	// The mono block maps, mono block colours, full block and block type
+	// descriptor trees are stored in order.
+	br := newBitReader(f.trees)
+	if f.mmapTree, err = parseHuffmanTree(br, f.MMapSize, f.mmapTree.buffer()); err != nil {
+		return errors.WithMessage(err, "unable to parse mono blocks maps Huffman tree")
+	}
+	if f.mclrTree, err = parseHuffmanTree(br, f.MClrSize, f.mclrTree.buffer()); err != nil {
+		return errors.WithMessage(err, "unable to parse mono blocks colours Huffman tree")
+	}
+	if f.fullTree, err = parseHuffmanTree(br, f.FullSize, f.fullTree.buffer()); err != nil {
+		return errors.WithMessage(err, "unable to parse full blocks Huffman tree")
+	}
+	if f.typeTree, err = parseHuffmanTree(br, f.TypeSize, f.typeTree.buffer()); err != nil {
+		return errors.WithMessage(err, "unable to parse block type descriptors Huffman tree")
+	}
+	return nil
+}
+
+// RawTrees returns the Huffman trees of the Smacker file as stored on disk;
+// i.e. the TreesSize bytes of tree data following the frame type table, not
+// the decoded tree structures.
+func (f *File) RawTrees() ([]byte, error) {
+	if f.trees == nil {
+		return nil, errors.New("Huffman trees not yet parsed")
+	}
+	buf := make([]byte, len(f.trees))
+	copy(buf, f.trees)
+	return buf, nil
+}
+
+// nodeFlag is set for branch nodes of Huffman trees.
+//
+// Huffman trees are stored as a flat list of nodes in depth-first order. A leaf
+// node stores its value, and a branch node stores the number of nodes in its
+// left subtree ORed with nodeFlag; the left subtree (bit 0) directly follows
+// the branch node, and is in turn followed by the right subtree (bit 1).
+const nodeFlag = 0x80000000
+
+// Limits of Huffman trees.
+const (
+	// Maximum number of nodes of 8-bit Huffman trees; i.e. a full binary tree
+	// with 256 leaves.
+	maxByteTreeNodes = 2*256 - 1
+	// Maximum depth of 8-bit Huffman trees.
+	maxByteTreeDepth = 32
+	// Maximum depth of 16-bit Huffman trees.
+	maxTreeDepth = 500
+	// Maximum depth of 16-bit Huffman trees decoded using a lookup table.
+	maxTableDepth = 12
+)
+
+// A HuffmanTree is a 16-bit Huffman tree, as used for decoding video data.
+//
+// The values of 16-bit trees are encoded in the tree data by two 8-bit Huffman
+// trees, one for the low byte and one for the high byte. Three escape values
+// mark leaves which hold the most recently decoded values of the tree; their
+// contents are updated as values are decoded.
+type HuffmanTree struct {
+	// Tree nodes in depth-first order.
+	nodes []uint32
+	// Node indices of the leaves holding the three most recently decoded
+	// values, most recent first.
+	last [3]int
+	// Lookup table indexed by the next tableBits bits of the bitstream, or nil
+	// if the tree is deeper than maxTableDepth. Each entry stores the node index
+	// of the leaf of the code prefixing the bits, shifted left by 8 and ORed
+	// with the length of the code. The table maps to node indices instead of
+	// values, as the leaves of escape values change while decoding.
+	table     []uint32
+	tableBits uint
+}
+
+// parseHuffmanTree parses a 16-bit Huffman tree from the given tree data, where
+// size is the allocation size of the tree in bytes. The nodes of the tree are
+// stored in buf if its capacity allows.
+func parseHuffmanTree(br *bitReader, size int, buf []uint32) (*HuffmanTree, error) {
+	present, err := br.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+	if present == 0 {
+		// Absent tree; every decoded value is zero.
+		t := &HuffmanTree{
+			nodes: append(buf[:0], 0, 0),
+			last:  [3]int{1, 1, 1},
+		}
+		return t, nil
+	}
+	lo, err := parseByteTree(br)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to parse low byte tree")
+	}
+	hi, err := parseByteTree(br)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to parse high byte tree")
+	}
+	var escapes [3]uint32
+	for i := range escapes {
+		if escapes[i], err = br.ReadBits(16); err != nil {
+			return nil, err
+		}
+	}
+	// The allocation size in bytes bounds the number of 32-bit nodes.
+	p := &treeParser{
+		br:      br,
+		lo:      lo,
+		hi:      hi,
+		escapes: escapes,
+		last:    [3]int{-1, -1, -1},
+		nodes:   buf[:0],
+		maxLen:  (size+3)>>2 + 4,
+	}
+	if err := p.parseNode(0); err != nil {
+		return nil, err
+	}
+	// Skip the terminating bit of the tree.
+	if _, err := br.ReadBit(); err != nil {
+		return nil, err
+	}
+	// Allocate leaves for escape values not present in the tree.
+	for i, index := range p.last {
+		if index == -1 {
+			p.last[i] = len(p.nodes)
+			p.nodes = append(p.nodes, 0)
+		}
+	}
+	t := &HuffmanTree{
+		nodes: p.nodes,
+		last:  p.last,
+	}
+	t.buildTable()
+	return t, nil
+}
+
+// treeParser tracks the state of parsing a 16-bit Huffman tree.
+type treeParser struct {
+	br *bitReader
+	// Low and high byte trees.
+	lo, hi *byteTree
+	// Escape values of leaves holding recently decoded values.
+	escapes [3]uint32
+	// Node indices of the leaves of each escape value; or -1 if not present.
+	last [3]int
+	// Parsed nodes.
+	nodes []uint32
+	// Maximum number of nodes.
+	maxLen int
+}
+
+// parseNode parses a node of a 16-bit Huffman tree at the given depth.
+func (p *treeParser) parseNode(depth int) error {
+	if len(p.nodes)+1 >= p.maxLen {
+		return errors.Wrapf(ErrInvalidHuffmanCode, "number of nodes exceeds allocation size (%d) at bit offset %d", p.maxLen, p.br.pos)
+	}
+	if depth > maxTreeDepth {
+		return errors.Wrapf(ErrInvalidHuffmanCode, "depth exceeds %d at bit offset %d", maxTreeDepth, p.br.pos)
+	}
+	isBranch, err := p.br.ReadBit()
+	if err != nil {
+		return err
+	}
+	if isBranch == 0 {
+		// Leaf node.
+		lo, err := p.lo.Decode(p.br)
+		if err != nil {
+			return err
+		}
+		hi, err := p.hi.Decode(p.br)
+		if err != nil {
+			return err
+		}
+		val := uint32(lo) | uint32(hi)<<8
+		for i, escape := range p.escapes {
+			if val == escape {
+				p.last[i] = len(p.nodes)
+				val = 0
+				break
+			}
+		}
+		p.nodes = append(p.nodes, val)
+		return nil
+	}
+	// Branch node.
+	index := len(p.nodes)
+	p.nodes = append(p.nodes, nodeFlag)
+	if err := p.parseNode(depth + 1); err != nil {
+		return err
+	}
+	p.nodes[index] |= uint32(len(p.nodes) - index - 1)
+	return p.parseNode(depth + 1)
+}
+
+// Decode decodes the next value of the Huffman tree from the bitstream.
+func (t *HuffmanTree) Decode(br *bitReader) (uint16, error) {
+	i, err := t.lookup(br)
+	if err != nil {
+		return 0, err
+	}
+	// Keep track of the three most recently decoded values.
+	val := t.nodes[i]
+	if val != t.nodes[t.last[0]] {
+		t.nodes[t.last[2]] = t.nodes[t.last[1]]
+		t.nodes[t.last[1]] = t.nodes[t.last[0]]
+		t.nodes[t.last[0]] = val
+	}
+	return uint16(val), nil
+}
+
+// lookup reads the code of the next value of the Huffman tree from the
+// bitstream, and returns the node index of its leaf. The lookup table is used
+// if present, unless fewer than tableBits bits remain.
+func (t *HuffmanTree) lookup(br *bitReader) (int, error) {
+	if t.table != nil {
+		if bits, ok := br.PeekBits(t.tableBits); ok {
+			e := t.table[bits]
+			br.pos += int(e & 0xFF)
+			return int(e >> 8), nil
+		}
+	}
+	return walk(t.nodes, br)
+}
+
+// buildTable builds the lookup table of the Huffman tree, if its depth is at
+// most maxTableDepth.
+func (t *HuffmanTree) buildTable() {
+	depth := t.MaxDepth()
+	if depth > maxTableDepth {
+		return
+	}
+	t.table = make([]uint32, 1<<uint(depth))
+	t.tableBits = uint(depth)
+	t.fillTable(0, 0, 0)
+}
+
+// fillTable fills the entries of the lookup table prefixed by the code of the
+// i-th node, the length of which is n bits.
+func (t *HuffmanTree) fillTable(i int, code uint32, n uint) {
+	if t.nodes[i]&nodeFlag == 0 {
+		// The first bit of a code is stored in the least significant bit of an
+		// index, so the remaining bits vary in steps of 1<<n.
+		for j := code; j < uint32(len(t.table)); j += 1 << n {
+			t.table[j] = uint32(i)<<8 | uint32(n)
+		}
+		return
+	}
+	t.fillTable(i+1, code, n+1)
+	t.fillTable(i+1+int(t.nodes[i]&^nodeFlag), code|1<<n, n+1)
+}
+
+// MaxDepth returns the maximum depth of the Huffman tree; i.e. the length in
+// bits of its longest code. The depth of absent trees is 0.
+func (t *HuffmanTree) MaxDepth() int {
+	return maxDepth(t.nodes, 0)
+}
+
+// maxDepth returns the maximum depth of the Huffman subtree rooted at the i-th
+// of the given nodes.
+func maxDepth(nodes []uint32, i int) int {
+	if nodes[i]&nodeFlag == 0 {
+		return 0
+	}
+	left := maxDepth(nodes, i+1)
+	right := maxDepth(nodes, i+1+int(nodes[i]&^nodeFlag))
+	if left > right {
+		return left + 1
+	}
+	return right + 1
+}
+
+// buffer returns the nodes of the Huffman tree for reuse as the backing storage
+// of another tree, or nil if t is nil.
+func (t *HuffmanTree) buffer() []uint32 {
+	if t == nil {
+		return nil
+	}
+	return t.nodes
+}
+
+// clone returns a copy of the Huffman tree, or nil if t is nil.
+func (t *HuffmanTree) clone() *HuffmanTree {
+	if t == nil {
+		return nil
+	}
+	nodes := make([]uint32, len(t.nodes))
+	copy(nodes, t.nodes)
+	// The lookup table is not updated while decoding, and is thus shared.
+	return &HuffmanTree{nodes: nodes, last: t.last, table: t.table, tableBits: t.tableBits}
+}
+
+// resetCache resets the three most recently decoded values of the Huffman tree
+// to zero.
+func (t *HuffmanTree) resetCache() {
+	for _, index := range t.last {
+		t.nodes[index] = 0
+	}
+}
+
+// byteTree is an 8-bit Huffman tree.
+type byteTree struct {
+	// Tree nodes in depth-first order.
+	nodes []uint32
+}
+
+// parseByteTree parses an 8-bit Huffman tree from the given tree data.
+func parseByteTree(br *bitReader) (*byteTree, error) {
+	present, err := br.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+	if present == 0 {
+		// Absent tree; every decoded value is zero.
+		return &byteTree{nodes: []uint32{0}}, nil
+	}
+	t := &byteTree{}
+	if err := t.parseNode(br, 0); err != nil {
+		return nil, err
+	}
+	// Skip the terminating bit of the tree.
+	if _, err := br.ReadBit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseNode parses a node of an 8-bit Huffman tree at the given depth.
+func (t *byteTree) parseNode(br *bitReader, depth int) error {
+	if len(t.nodes) >= maxByteTreeNodes {
+		return errors.Wrapf(ErrInvalidHuffmanCode, "number of nodes of 8-bit tree exceeds %d at bit offset %d", maxByteTreeNodes, br.pos)
+	}
+	if depth > maxByteTreeDepth {
+		return errors.Wrapf(ErrInvalidHuffmanCode, "depth of 8-bit tree exceeds %d at bit offset %d", maxByteTreeDepth, br.pos)
+	}
+	isBranch, err := br.ReadBit()
+	if err != nil {
+		return err
+	}
+	if isBranch == 0 {
+		// Leaf node.
+		val, err := br.ReadBits(8)
+		if err != nil {
+			return err
+		}
+		t.nodes = append(t.nodes, val)
+		return nil
+	}
+	// Branch node.
+	index := len(t.nodes)
+	t.nodes = append(t.nodes, nodeFlag)
+	if err := t.parseNode(br, depth+1); err != nil {
+		return err
+	}
+	t.nodes[index] |= uint32(len(t.nodes) - index - 1)
+	return t.parseNode(br, depth+1)
+}
+
+// Decode decodes the next value of the Huffman tree from the bitstream.
+func (t *byteTree) Decode(br *bitReader) (uint8, error) {
+	i, err := walk(t.nodes, br)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(t.nodes[i]), nil
+}
+
+// walk walks the Huffman tree of the given nodes from the root, reading one bit
+// of the bitstream per branch, and returns the node index of the leaf reached.
+// An error wrapping ErrInvalidHuffmanCode is returned if the bitstream ends
+// before a leaf is reached.
+func walk(nodes []uint32, br *bitReader) (int, error) {
+	start := br.pos
+	i := 0
+	for nodes[i]&nodeFlag != 0 {
+		bit, err := br.ReadBit()
+		if err != nil {
+			return 0, errors.Wrapf(ErrInvalidHuffmanCode, "bitstream ends within code starting at bit offset %d", start)
+		}
+		if bit == 1 {
+			// Skip the left subtree.
+			i += int(nodes[i] &^ nodeFlag)
+		}
+		i++
+	}
+	return i, nil
+}
@@ -0,0 +1,302 @@
+package smk
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeEscapeTree writes a balanced 16-bit Huffman tree of the given sorted
+// values to the bitstream, as for writeHuffmanTree, but with the given escape
+// values; escape values present in vals are stored as leaves of the tree.
+func writeEscapeTree(bw *bitWriter, vals []uint32, escapes [3]uint32) map[uint32]huffmanCode {
+	bw.WriteBit(1)
+	seen := make(map[uint32]bool)
+	var byteVals []uint32
+	for _, val := range vals {
+		for _, b := range []uint32{val & 0xFF, val >> 8} {
+			if !seen[b] {
+				seen[b] = true
+				byteVals = append(byteVals, b)
+			}
+		}
+	}
+	sort.Slice(byteVals, func(i, j int) bool { return byteVals[i] < byteVals[j] })
+	// The same byte tree is used for the low and high byte.
+	loCodes := writeByteTree(bw, byteVals)
+	hiCodes := writeByteTree(bw, byteVals)
+	for _, escape := range escapes {
+		bw.WriteBits(escape, 16)
+	}
+	codes := make(map[uint32]huffmanCode)
+	writeTreeNode(bw, vals, huffmanCode{}, codes, func(val uint32) {
+		loCodes[val&0xFF].write(bw)
+		hiCodes[val>>8].write(bw)
+	})
+	// Terminating bit of the tree.
+	bw.WriteBit(0)
+	return codes
+}
+
+func TestHuffmanTreeEscapes(t *testing.T) {
+	const (
+		a, b       = 0x0101, 0x0202
+		e0, e1, e2 = 0xE000, 0xE001, 0xE002
+	)
+	bw := &bitWriter{}
+	codes := writeEscapeTree(bw, []uint32{a, b, e0, e1, e2}, [3]uint32{e0, e1, e2})
+	tree, err := parseHuffmanTree(newBitReader(bw.Bytes()), treeAllocSize(5), nil)
+	if err != nil {
+		t.Fatalf("unable to parse Huffman tree; %+v", err)
+	}
+	// The leaves of escape values hold the most recently decoded value (e0),
+	// and the two preceding distinct values (e1 and e2); each is initially 0.
+	// A decoded value is shifted into the leaves unless equal to the most
+	// recent value.
+	golden := []struct {
+		code uint32
+		want uint16
+		// Most recently decoded values after decoding.
+		last [3]uint32
+	}{
+		{code: e0, want: 0, last: [3]uint32{0, 0, 0}},
+		{code: a, want: a, last: [3]uint32{a, 0, 0}},
+		{code: b, want: b, last: [3]uint32{b, a, 0}},
+		{code: e1, want: a, last: [3]uint32{a, b, a}},
+		// Equal to the most recent value; not shifted.
+		{code: e2, want: a, last: [3]uint32{a, b, a}},
+		{code: e1, want: b, last: [3]uint32{b, a, b}},
+		{code: e0, want: b, last: [3]uint32{b, a, b}},
+		{code: a, want: a, last: [3]uint32{a, b, a}},
+	}
+	bw = &bitWriter{}
+	for _, g := range golden {
+		codes[g.code].write(bw)
+	}
+	br := newBitReader(bw.Bytes())
+	for i, g := range golden {
+		got, err := tree.Decode(br)
+		if err != nil {
+			t.Fatalf("value %d: unable to decode; %+v", i, err)
+		}
+		if got != g.want {
+			t.Errorf("value %d: expected 0x%04X, got 0x%04X", i, g.want, got)
+		}
+		var last [3]uint32
+		for j, index := range tree.last {
+			last[j] = tree.nodes[index]
+		}
+		if last != g.last {
+			t.Errorf("value %d: recently decoded values mismatch; expected %04X, got %04X", i, g.last, last)
+		}
+	}
+	// Resetting the cache sets the recently decoded values to 0.
+	tree.resetCache()
+	bw = &bitWriter{}
+	for _, code := range []uint32{e0, e1, e2} {
+		codes[code].write(bw)
+	}
+	br = newBitReader(bw.Bytes())
+	for i := 0; i < 3; i++ {
+		if got, err := tree.Decode(br); err != nil || got != 0 {
+			t.Errorf("escape %d after reset: expected 0x0000, got 0x%04X (%v)", i, got, err)
+		}
+	}
+}
+
+func TestHuffmanTreeEscapesPerFrame(t *testing.T) {
+	// Frame 0 is a solid block of colour 5; frame 1 holds the escape code of
+	// the most recently decoded value, which is reset to 0 for each frame and
+	// thus decodes to a mono block of colour 0.
+	const (
+		solid      = 5<<8 | blockSolid
+		e0, e1, e2 = 0xE000, 0xE001, 0xE002
+	)
+	bw := &bitWriter{}
+	// Absent mono blocks maps, mono blocks colours and full blocks trees.
+	for i := 0; i < 3; i++ {
+		bw.WriteBit(0)
+	}
+	codes := writeEscapeTree(bw, []uint32{solid, e0, e1, e2}, [3]uint32{e0, e1, e2})
+	trees := bw.Bytes()
+	var frames []testFrame
+	for i, code := range []uint32{solid, e0} {
+		bw := &bitWriter{}
+		codes[code].write(bw)
+		frames = append(frames, testFrame{key: i == 0, data: bw.Bytes()})
+	}
+	f := parseBytes(t, buildFile(t, FileHeader{TypeSize: treeAllocSize(4)}, trees, frames))
+	for i, want := range []uint8{5, 0} {
+		img, err := f.DecodeFrame(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		if !bytes.Equal(img.Pix, bytes.Repeat([]uint8{want}, 16)) {
+			t.Errorf("frame %d: expected pixels of colour %d, got %v", i, want, img.Pix)
+		}
+	}
+}
+
+func TestHuffmanTreeMaxDepth(t *testing.T) {
+	golden := []struct {
+		n    int
+		want int
+	}{
+		// Absent tree.
+		{n: 0, want: 0},
+		{n: 1, want: 0},
+		{n: 2, want: 1},
+		{n: 3, want: 2},
+		{n: 5, want: 3},
+		{n: 8, want: 3},
+		{n: 300, want: 9},
+	}
+	for _, g := range golden {
+		var vals []uint32
+		for i := 0; i < g.n; i++ {
+			vals = append(vals, uint32(i)*0x81)
+		}
+		bw := &bitWriter{}
+		if _, err := writeHuffmanTree(bw, vals); err != nil {
+			t.Fatalf("%d values: unable to write Huffman tree; %+v", g.n, err)
+		}
+		tree, err := parseHuffmanTree(newBitReader(bw.Bytes()), treeAllocSize(g.n), nil)
+		if err != nil {
+			t.Fatalf("%d values: unable to parse Huffman tree; %+v", g.n, err)
+		}
+		if got := tree.MaxDepth(); got != g.want {
+			t.Errorf("%d values: depth mismatch; expected %d, got %d", g.n, g.want, got)
+		}
+	}
+}
+
+func TestHuffmanTreeLookup(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// Trees deeper than maxTableDepth are decoded by walking the tree.
+	for _, n := range []int{1, 2, 7, 40, 1 << maxTableDepth, 1<<maxTableDepth + 1} {
+		var vals []uint32
+		for i := 0; i < n; i++ {
+			vals = append(vals, uint32(i)*7)
+		}
+		bw := &bitWriter{}
+		codes, err := writeHuffmanTree(bw, vals)
+		if err != nil {
+			t.Fatalf("%d values: unable to write Huffman tree; %+v", n, err)
+		}
+		tree, err := parseHuffmanTree(newBitReader(bw.Bytes()), treeAllocSize(n), nil)
+		if err != nil {
+			t.Fatalf("%d values: unable to parse Huffman tree; %+v", n, err)
+		}
+		if hasTable := tree.table != nil; hasTable != (tree.MaxDepth() <= maxTableDepth) {
+			t.Errorf("%d values: expected lookup table for depth %d <= %d, got %v", n, tree.MaxDepth(), maxTableDepth, hasTable)
+		}
+		walker := tree.clone()
+		walker.table = nil
+		// Values are decoded to the end of the bitstream, so that fewer than
+		// tableBits bits remain for the last codes.
+		bw = &bitWriter{}
+		var want []uint16
+		for i := 0; i < 1000; i++ {
+			val := vals[rnd.Intn(n)]
+			codes[val].write(bw)
+			want = append(want, uint16(val))
+		}
+		data := bw.Bytes()
+		for _, tr := range []*HuffmanTree{tree, walker} {
+			br := newBitReader(data)
+			for i, w := range want {
+				got, err := tr.Decode(br)
+				if err != nil {
+					t.Fatalf("%d values: value %d: unable to decode; %+v", n, i, err)
+				}
+				if got != w {
+					t.Fatalf("%d values: value %d: expected 0x%04X, got 0x%04X", n, i, w, got)
+				}
+			}
+		}
+		if tree.last != walker.last {
+			t.Fatalf("%d values: escape leaves mismatch", n)
+		}
+		for _, index := range tree.last {
+			if tree.nodes[index] != walker.nodes[index] {
+				t.Errorf("%d values: recently decoded value mismatch; expected 0x%04X, got 0x%04X", n, walker.nodes[index], tree.nodes[index])
+			}
+		}
+	}
+}
+
+func TestRawTrees(t *testing.T) {
+	raw := encodeFrames(t, testFrames(3, 8, 4), 100)
+	f := parseBytes(t, raw)
+	got, err := f.RawTrees()
+	if err != nil {
+		t.Fatalf("unable to get Huffman trees; %+v", err)
+	}
+	// The Huffman trees follow the file header and the frame size and type
+	// tables.
+	start := headerSize + 5*f.NFrames
+	want := raw[start : start+f.TreesSize]
+	if !bytes.Equal(got, want) {
+		t.Errorf("Huffman trees mismatch; expected %X, got %X", want, got)
+	}
+	// The returned slice is a copy.
+	for i := range got {
+		got[i] ^= 0xFF
+	}
+	if again, err := f.RawTrees(); err != nil || !bytes.Equal(again, want) {
+		t.Errorf("Huffman trees modified through returned slice; expected %X, got %X (%v)", want, again, err)
+	}
+	if _, err := (&File{}).RawTrees(); err == nil {
+		t.Error("expected error for unparsed Huffman trees")
+	}
+}
+
+func TestInvalidHuffmanCode(t *testing.T) {
+	// 9-bit codes; the bitstream ends within the second code.
+	var vals []uint32
+	for i := 0; i < 300; i++ {
+		vals = append(vals, uint32(i))
+	}
+	bw := &bitWriter{}
+	codes, err := writeHuffmanTree(bw, vals)
+	if err != nil {
+		t.Fatalf("unable to write Huffman tree; %+v", err)
+	}
+	tree, err := parseHuffmanTree(newBitReader(bw.Bytes()), treeAllocSize(len(vals)), nil)
+	if err != nil {
+		t.Fatalf("unable to parse Huffman tree; %+v", err)
+	}
+	if n := codes[299].n; n != 9 {
+		t.Fatalf("expected code length of 9 bits, got %d", n)
+	}
+	bw = &bitWriter{}
+	codes[299].write(bw)
+	codes[299].write(bw)
+	br := newBitReader(bw.Bytes()[:2])
+	if _, err := tree.Decode(br); err != nil {
+		t.Fatalf("unable to decode first value; %+v", err)
+	}
+	_, err = tree.Decode(br)
+	if !errors.Is(err, ErrInvalidHuffmanCode) {
+		t.Fatalf("expected ErrInvalidHuffmanCode, got %v", err)
+	}
+	if want := "bit offset 9"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error to report %q, got %q", want, err)
+	}
+	// The video data of a 32x32 frame of 64 blocks holds the 1-bit block type
+	// descriptors of 32 void blocks.
+	tt := newTestTrees(t, nil, nil, nil, []uint32{blockVoid, blockSolid})
+	hdr := tt.header()
+	hdr.Width, hdr.Height = 32, 32
+	frames := []testFrame{{key: true, data: make([]byte, 4)}}
+	_, err = parseBytes(t, buildFile(t, hdr, tt.data, frames)).DecodeFrame(0)
+	if !errors.Is(err, ErrInvalidHuffmanCode) {
+		t.Fatalf("expected ErrInvalidHuffmanCode, got %v", err)
+	}
+	if want := "bit offset 32"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error to report %q, got %q", want, err)
+	}
+}
@@ -0,0 +1,87 @@
+package smk
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Info is a summary of a Smacker file, as derived from the file header.
+type Info struct {
+	// Version of the Smacker file format; 2 or 4.
+	Version int
+	// Frame dimensions.
+	Width, Height int
+	// Number of frames, excluding the ring frame.
+	NFrames int
+	// Frames per second.
+	FPS float64
+	// Playback duration.
+	Duration time.Duration
+	// Audio tracks with audio data present.
+	AudioTracks []AudioTrackInfo
+	// File has a ring frame.
+	RingFrame bool
+	// Total size in bytes of the frame data, including the ring frame.
+	DataSize int64
+}
+
+// AudioTrackInfo describes the audio format of an audio track.
+type AudioTrackInfo struct {
+	// Track index.
+	Track int
+	// Sample rate in Hz.
+	SampleRate int
+	// Bits per sample.
+	BitRate int
+	// Number of channels.
+	NChannels int
+}
+
+// Info returns a summary of the Smacker file.
+func (f *File) Info() Info {
+	info := Info{
+		Version:   f.Version(),
+		Width:     f.Width,
+		Height:    f.Height,
+		NFrames:   f.NFrames,
+		FPS:       f.FrameRate.FPS(),
+		Duration:  f.Duration(),
+		RingFrame: f.HasRingFrame(),
+	}
+	// The frame offsets are only present for parsed files.
+	if len(f.offsets) > 0 {
+		info.DataSize = f.offsets[len(f.offsets)-1] - f.offsets[0]
+	}
+	for track, t := range f.TrackInfo {
+		if !t.HasAudioData() {
+			continue
+		}
+		info.AudioTracks = append(info.AudioTracks, AudioTrackInfo{
+			Track:      track,
+			SampleRate: t.SampleRate(),
+			BitRate:    t.BitRate(),
+			NChannels:  t.NChannels(),
+		})
+	}
+	return info
+}
+
+// String returns a human-readable multi-line summary of the Smacker file.
+func (info Info) String() string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "version:    SMK%d\n", info.Version)
+	fmt.Fprintf(buf, "dimensions: %dx%d\n", info.Width, info.Height)
+	fmt.Fprintf(buf, "frames:     %d", info.NFrames)
+	if info.RingFrame {
+		buf.WriteString(" (+ ring frame)")
+	}
+	buf.WriteString("\n")
+	fmt.Fprintf(buf, "fps:        %.3f\n", info.FPS)
+	fmt.Fprintf(buf, "duration:   %v\n", info.Duration)
+	fmt.Fprintf(buf, "data size:  %d bytes\n", info.DataSize)
+	for _, t := range info.AudioTracks {
+		fmt.Fprintf(buf, "track %d:    %d Hz, %d-bit, %d channel(s)\n", t.Track, t.SampleRate, t.BitRate, t.NChannels)
+	}
+	return buf.String()
+}
@@ -0,0 +1,60 @@
+package smk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfo(t *testing.T) {
+	hdr := FileHeader{Width: 8, Height: 4, Flags: FlagRingFrame, FrameRate: 40}
+	hdr.TrackInfo[0] = testMono16
+	hdr.TrackInfo[2] = TrackInfo(0x50000000 | 11025)
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, data: make([]byte, 4)},
+		{data: make([]byte, 8)},
+		// Ring frame.
+		{data: make([]byte, 4)},
+	})
+	info := parseBytes(t, raw).Info()
+	want := Info{
+		Version:  2,
+		Width:    8,
+		Height:   4,
+		NFrames:  2,
+		FPS:      25,
+		Duration: 80 * time.Millisecond,
+		AudioTracks: []AudioTrackInfo{
+			{Track: 0, SampleRate: 22050, BitRate: 16, NChannels: 1},
+			{Track: 2, SampleRate: 11025, BitRate: 8, NChannels: 2},
+		},
+		RingFrame: true,
+		DataSize:  16,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("info mismatch; expected %+v, got %+v", want, info)
+	}
+	const wantString = `version:    SMK2
+dimensions: 8x4
+frames:     2 (+ ring frame)
+fps:        25.000
+duration:   80ms
+data size:  16 bytes
+track 0:    22050 Hz, 16-bit, 1 channel(s)
+track 2:    11025 Hz, 8-bit, 2 channel(s)
+`
+	if got := info.String(); got != wantString {
+		t.Errorf("string mismatch; expected\n%s\ngot\n%s", wantString, got)
+	}
+}
+
+func TestInfoZeroValue(t *testing.T) {
+	info := (&File{}).Info()
+	if info.DataSize != 0 || info.NFrames != 0 || len(info.AudioTracks) != 0 {
+		t.Errorf("expected empty summary of zero value File, got %+v", info)
+	}
+	if !strings.Contains(info.String(), "data size:  0 bytes") {
+		t.Errorf("expected summary to report no frame data, got\n%s", info.String())
+	}
+}
@@ -0,0 +1,115 @@
+package smk
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/pkg/errors"
+)
+
+// FrameIter is an iterator over the video frames of a Smacker file.
+//
+// The iterator retains no decoded frames; memory use is bounded by the frame
+// buffer of the decoder, which is updated in place as each frame is a delta of
+// the preceding frame, the frame data buffer, and the image of the current
+// frame. Use DecodeAll to retain every decoded frame.
+//
+// Example usage:
+//
+//    it := f.Frames()
+//    for it.Next() {
+//       img := it.Frame()
+//       ...
+//    }
+//    if err := it.Err(); err != nil {
+//       ...
+//    }
+type FrameIter struct {
+	// Smacker file.
+	f *File
+	// Image of the current frame; reused between iterations.
+	img *image.Paletted
+	// Loop back to the first frame after the last frame.
+	loop bool
+	// First decoding error encountered.
+	err error
+}
+
+// Frames returns an iterator over the video frames of the Smacker file,
+// starting at the next frame to decode. Iteration stops after the last frame,
+// excluding the ring frame.
+func (f *File) Frames() *FrameIter {
+	return &FrameIter{f: f}
+}
+
+// LoopFrames returns an iterator over the video frames of the Smacker file, as
+// for Frames, which loops back to the first frame after the last frame; thus,
+// iteration only stops on a decoding error.
+//
+// Looping requires random access to the frame data, as provided by files parsed
+// using ParseReaderAt. If the file has a ring frame, it is decoded in place of
+// the first frame when looping back, as it holds the first frame stored as a
+// delta of the last frame.
+func (f *File) LoopFrames() *FrameIter {
+	return &FrameIter{f: f, loop: true}
+}
+
+// Next advances the iterator to the next frame, which will then be available
+// through Frame. It returns false when the iteration stops, either by reaching
+// the last frame or on a decoding error, the latter of which is reported by Err.
+func (it *FrameIter) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	switch {
+	case it.f.cur < it.f.NFrames:
+		if err := it.f.decodeNextFrame(); err != nil {
+			it.err = errors.WithMessagef(err, "unable to decode frame %d", it.f.cur)
+			return false
+		}
+	case it.loop:
+		if err := it.f.loopBack(); err != nil {
+			it.err = errors.WithMessage(err, "unable to loop back to first frame")
+			return false
+		}
+	default:
+		return false
+	}
+	if it.img == nil {
+		pal := make(color.Palette, len(it.f.pal))
+		it.img = image.NewPaletted(it.f.displayBounds(), pal)
+	}
+	it.f.render(it.img.Pix)
+	copy(it.img.Palette, it.f.pal)
+	return true
+}
+
+// loopBack decodes the first frame after the last frame of a random access
+// file, by decoding the ring frame if present, and by decoding the first frame
+// anew otherwise.
+func (f *File) loopBack() error {
+	if f.ra == nil {
+		return errors.New("looping requires random access to frame data")
+	}
+	if !f.HasRingFrame() {
+		return f.decodeFrameAt(0)
+	}
+	// The ring frame directly follows the last frame.
+	if err := f.decodeNextFrame(); err != nil {
+		return errors.WithMessage(err, "unable to decode ring frame")
+	}
+	f.cur = 1
+	return nil
+}
+
+// Frame returns the current frame of the iterator. The image is reused between
+// iterations, and is thus only valid until the next call to Next.
+func (it *FrameIter) Frame() *image.Paletted {
+	return it.img
+}
+
+// Err returns the first decoding error encountered by the iterator, or nil if
+// the iteration stopped by reaching the last frame.
+func (it *FrameIter) Err() error {
+	return it.err
+}
@@ -0,0 +1,122 @@
+package smk
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+// loopFile returns a Smacker file of two frames, which set palette entry 0 to
+// the first and second colour respectively, followed by a ring frame which sets
+// the entry to the third colour if ring is set.
+func loopFile(t *testing.T, ring bool) (raw []byte, colors [3]color.Color) {
+	t.Helper()
+	ops := [3][]byte{{63, 0, 32}, {1, 2, 3}, {4, 5, 6}}
+	var frames []testFrame
+	for i, op := range ops {
+		colors[i] = color.RGBA{R: expand6(op[0]), G: expand6(op[1]), B: expand6(op[2]), A: 0xFF}
+		if i == 2 && !ring {
+			break
+		}
+		rec := palRecord(append(op, 0x80|127, 0x80|126)...)
+		frames = append(frames, testFrame{key: i == 0, typ: FrameTypePaletteRecord, data: rec})
+	}
+	hdr := FileHeader{}
+	if ring {
+		hdr.Flags = FlagRingFrame
+	}
+	return buildFile(t, hdr, absentTrees, frames), colors
+}
+
+func TestFrames(t *testing.T) {
+	raw, colors := loopFile(t, true)
+	f := parseBytes(t, raw)
+	it := f.Frames()
+	n := 0
+	for it.Next() {
+		if got := it.Frame().Palette[0]; got != colors[n] {
+			t.Errorf("frame %d: palette entry 0 mismatch; expected %v, got %v", n, colors[n], got)
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("unable to iterate over frames; %+v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 frames excluding the ring frame, got %d", n)
+	}
+}
+
+func TestLoopFrames(t *testing.T) {
+	golden := []struct {
+		ring bool
+		// Index of the colour of palette entry 0 in each iteration.
+		want []int
+	}{
+		{ring: true, want: []int{0, 1, 2, 1, 2, 1}},
+		{ring: false, want: []int{0, 1, 0, 1, 0, 1}},
+	}
+	for _, g := range golden {
+		raw, colors := loopFile(t, g.ring)
+		f := parseBytesAt(t, raw)
+		if f.HasRingFrame() != g.ring {
+			t.Errorf("ring %v: ring frame mismatch; expected %v, got %v", g.ring, g.ring, f.HasRingFrame())
+		}
+		it := f.LoopFrames()
+		for n, want := range g.want {
+			if !it.Next() {
+				t.Fatalf("ring %v: iteration %d: unable to loop over frames; %+v", g.ring, n, it.Err())
+			}
+			if got := it.Frame().Palette[0]; got != colors[want] {
+				t.Errorf("ring %v: iteration %d: palette entry 0 mismatch; expected %v, got %v", g.ring, n, colors[want], got)
+			}
+		}
+	}
+	// Looping requires random access.
+	raw, _ := loopFile(t, true)
+	it := parseBytes(t, raw).LoopFrames()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 frames before looping back, got %d", n)
+	}
+	if it.Err() == nil {
+		t.Error("expected error for looping without random access")
+	}
+}
+
+func TestFramesAllocs(t *testing.T) {
+	const runs = 100
+	imgs := samePaletteFrames(runs+2, 16, 16)
+	it := parseBytes(t, encodeFrames(t, imgs, 100)).Frames()
+	// The image of the iterator is allocated by the first step.
+	if !it.Next() {
+		t.Fatalf("unable to decode frame; %+v", it.Err())
+	}
+	n := 1
+	allocs := testing.AllocsPerRun(runs, func() {
+		if !it.Next() {
+			t.Fatalf("frame %d: unable to decode frame; %+v", n, it.Err())
+		}
+		n++
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per frame, got %v", allocs)
+	}
+	if !bytes.Equal(it.Frame().Pix, imgs[n-1].Pix) {
+		t.Errorf("frame %d: pixel mismatch", n-1)
+	}
+}
+
+func BenchmarkLoopFrames(b *testing.B) {
+	it := parseBytesAt(b, encodeFrames(b, samePaletteFrames(8, 320, 200), 100)).LoopFrames()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !it.Next() {
+			b.Fatalf("unable to decode frame; %+v", it.Err())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package smk
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// TrailingMetadata returns a copy of the bytes following the frame data of the
+// Smacker file, including the ring frame; some toolchains append a metadata
+// blob, which may be parsed using ParseTrailingMetadata. A nil slice is
+// returned if no bytes follow the frame data.
+//
+// Trailing bytes are only accessible to files parsed using ParseReaderAt.
+func (f *File) TrailingMetadata() ([]byte, error) {
+	if f.ra == nil {
+		return nil, errors.New("reading trailing metadata requires random access to file data")
+	}
+	end := f.offsets[len(f.offsets)-1]
+	if end > f.size {
+		return nil, errors.Wrapf(ErrTruncatedFrameData, "frame data ends at offset %d, beyond file size %d", end, f.size)
+	}
+	if end == f.size {
+		return nil, nil
+	}
+	buf := make([]byte, f.size-end)
+	if err := f.readAt(buf, end); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ParseTrailingMetadata parses a metadata blob appended after the frame data of
+// a Smacker file by some toolchains. The recognized format consists of
+// key=value pairs separated by newlines or NUL bytes; trailing padding of NUL
+// bytes is ignored.
+//
+// An error is returned if the blob is not in a recognized format, in which case
+// callers should retain the raw bytes.
+func ParseTrailingMetadata(b []byte) (map[string]string, error) {
+	isSep := func(r rune) bool {
+		return r == '\n' || r == '\r' || r == 0
+	}
+	fields := bytes.FieldsFunc(b, isSep)
+	if len(fields) == 0 {
+		return nil, errors.New("unrecognized trailing metadata format; no key=value pairs")
+	}
+	m := make(map[string]string)
+	for _, field := range fields {
+		pos := bytes.IndexByte(field, '=')
+		if pos <= 0 {
+			return nil, errors.Errorf("unrecognized trailing metadata format; invalid key=value pair %q", field)
+		}
+		key := string(bytes.TrimSpace(field[:pos]))
+		val := string(bytes.TrimSpace(field[pos+1:]))
+		m[key] = val
+	}
+	return m, nil
+}
@@ -0,0 +1,68 @@
+package smk
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTrailingMetadata(t *testing.T) {
+	raw, _ := loopFile(t, true)
+	// No trailing bytes.
+	got, err := parseBytesAt(t, raw).TrailingMetadata()
+	if err != nil {
+		t.Fatalf("unable to read trailing metadata; %+v", err)
+	}
+	if got != nil {
+		t.Errorf("expected no trailing metadata, got %q", got)
+	}
+	// Trailing bytes follow the ring frame.
+	blob := []byte("title=Intro\nauthor=Jane\x00\x00")
+	got, err = parseBytesAt(t, append(append([]byte{}, raw...), blob...)).TrailingMetadata()
+	if err != nil {
+		t.Fatalf("unable to read trailing metadata; %+v", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("trailing metadata mismatch; expected %q, got %q", blob, got)
+	}
+	// Truncated frame data.
+	if _, err := parseBytesAt(t, raw[:len(raw)-1]).TrailingMetadata(); !errors.Is(err, ErrTruncatedFrameData) {
+		t.Errorf("expected ErrTruncatedFrameData, got %v", err)
+	}
+	// Trailing bytes are inaccessible without random access.
+	if _, err := parseBytes(t, raw).TrailingMetadata(); err == nil {
+		t.Error("expected error for reading trailing metadata without random access")
+	}
+}
+
+func TestParseTrailingMetadata(t *testing.T) {
+	golden := []struct {
+		in   string
+		want map[string]string
+		err  bool
+	}{
+		{in: "title=Intro\nauthor=Jane", want: map[string]string{"title": "Intro", "author": "Jane"}},
+		// NUL separators, CRLF line endings and NUL padding.
+		{in: "title = Intro\r\nauthor=Jane\x00tool=smk=1\x00\x00\x00", want: map[string]string{"title": "Intro", "author": "Jane", "tool": "smk=1"}},
+		{in: "\x00\x00\x00", err: true},
+		{in: "title=Intro\nplain text", err: true},
+		{in: "=value", err: true},
+	}
+	for _, g := range golden {
+		got, err := ParseTrailingMetadata([]byte(g.in))
+		if g.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", g.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unable to parse trailing metadata; %+v", g.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%q: metadata mismatch; expected %v, got %v", g.in, g.want, got)
+		}
+	}
+}
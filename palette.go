@@ -0,0 +1,117 @@
+package smk
+
+import (
+	"image/color"
+
+	"github.com/pkg/errors"
+)
+
+// Palette returns the palette in effect after the most recently decoded frame,
+// or nil if no frame has been decoded.
+func (f *File) Palette() color.Palette {
+	if len(f.pal) == 0 {
+		return nil
+	}
+	pal := make(color.Palette, len(f.pal))
+	copy(pal, f.pal)
+	return pal
+}
+
+// PaletteChanged reports whether the palette record of the most recently
+// decoded frame changed the palette.
+func (f *File) PaletteChanged() bool {
+	return f.palChanged
+}
+
+// decodePalette decodes the given palette record of a frame (excluding the size
+// byte), and updates the current palette accordingly.
+//
+// The palette record consists of a sequence of opcodes, each updating a run of
+// consecutive palette entries, until all 256 entries have been processed:
+//
+//    1xxxxxxx          - skip x+1 entries, retaining their previous colours
+//    01xxxxxx yyyyyyyy - copy x+1 entries of the previous palette, starting at
+//                        entry y
+//    00rrrrrr gggggggg bbbbbbbb - set one entry to the given 6-bit RGB colour
+func (f *File) decodePalette(data []byte) error {
+	f.prevPal = append(f.prevPal[:0], f.pal...)
+	prev := f.prevPal
+	for i := 0; i < 256; {
+		if len(data) < 1 {
+			return errors.Errorf("invalid palette record; missing opcode for entry %d", i)
+		}
+		op := data[0]
+		switch {
+		case op&0x80 != 0:
+			// Skip entries.
+			i += int(op&0x7F) + 1
+			data = data[1:]
+		case op&0x40 != 0:
+			// Copy entries of the previous palette.
+			if len(data) < 2 {
+				return errors.Errorf("invalid palette record; missing copy offset for entry %d", i)
+			}
+			n := int(op&0x3F) + 1
+			off := int(data[1])
+			if off+n > 256 {
+				return errors.Errorf("invalid palette record; copy of %d entries at offset %d extends beyond palette", n, off)
+			}
+			for j := 0; j < n && i < 256; j++ {
+				f.pal[i] = prev[off+j]
+				i++
+			}
+			data = data[2:]
+		default:
+			// Set entry.
+			if len(data) < 3 {
+				return errors.Errorf("invalid palette record; missing colour of entry %d", i)
+			}
+			c := color.RGBA{
+				R: expand6(data[0]),
+				G: expand6(data[1]),
+				B: expand6(data[2]),
+				A: 0xFF,
+			}
+			// Storing a colour in the palette allocates; reuse the colour most
+			// recently set at the entry if unchanged.
+			if f.colors[i] != c {
+				f.colors[i] = c
+			}
+			f.pal[i] = f.colors[i]
+			i++
+			data = data[3:]
+		}
+	}
+	return nil
+}
+
+// grayPalette returns a grayscale palette, ranging from black at entry 0 to
+// white at entry 255.
+func grayPalette() color.Palette {
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.Gray{Y: uint8(i)}
+	}
+	return pal
+}
+
+// expand6 expands the given 6-bit colour component to 8 bits, by replicating
+// the most significant bits in the low bits; thus mapping 0x00 to 0x00 and 0x3F
+// to 0xFF.
+func expand6(c uint8) uint8 {
+	c &= 0x3F
+	return c<<2 | c>>4
+}
+
+// palEqual reports whether the given palettes are equal.
+func palEqual(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
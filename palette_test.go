@@ -0,0 +1,104 @@
+package smk
+
+import (
+	"image/color"
+	"testing"
+)
+
+// palRecord returns a palette record of the given opcodes, including the size
+// byte and padding.
+func palRecord(ops ...byte) []byte {
+	n := 1 + len(ops)
+	for n%4 != 0 {
+		n++
+	}
+	rec := make([]byte, n)
+	rec[0] = uint8(n / 4)
+	copy(rec[1:], ops)
+	return rec
+}
+
+func TestPalette(t *testing.T) {
+	raw := buildFile(t, FileHeader{}, absentTrees, []testFrame{
+		// Set entry 0 and 1, and skip the remaining 254 entries.
+		{key: true, typ: FrameTypePaletteRecord, data: palRecord(63, 0, 32, 1, 2, 3, 0x80|127, 0x80|126)},
+		// No palette record.
+		{},
+		// Copy entry 1 to entry 0, set entry 1, and skip 254 entries.
+		{typ: FrameTypePaletteRecord, data: palRecord(0x40, 1, 16, 32, 48, 0x80|127, 0x80|126)},
+	})
+	f := parseBytes(t, raw)
+	if pal := f.Palette(); pal != nil {
+		t.Errorf("expected nil palette before decoding, got %d entries", len(pal))
+	}
+	golden := []struct {
+		want []color.RGBA
+	}{
+		{want: []color.RGBA{{R: 0xFF, G: 0x00, B: 0x82, A: 0xFF}, {R: 0x04, G: 0x08, B: 0x0C, A: 0xFF}, {A: 0xFF}}},
+		{want: []color.RGBA{{R: 0xFF, G: 0x00, B: 0x82, A: 0xFF}, {R: 0x04, G: 0x08, B: 0x0C, A: 0xFF}, {A: 0xFF}}},
+		{want: []color.RGBA{{R: 0x04, G: 0x08, B: 0x0C, A: 0xFF}, {R: 0x41, G: 0x82, B: 0xC3, A: 0xFF}, {A: 0xFF}}},
+	}
+	for i, g := range golden {
+		img, err := f.DecodeFrame(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		pal := f.Palette()
+		if len(pal) != 256 {
+			t.Fatalf("frame %d: expected 256 palette entries, got %d", i, len(pal))
+		}
+		for j, want := range g.want {
+			if pal[j] != want {
+				t.Errorf("frame %d: palette entry %d mismatch; expected %v, got %v", i, j, want, pal[j])
+			}
+			if img.Palette[j] != want {
+				t.Errorf("frame %d: image palette entry %d mismatch; expected %v, got %v", i, j, want, img.Palette[j])
+			}
+		}
+	}
+}
+
+func TestExpand6(t *testing.T) {
+	golden := []struct {
+		c, want uint8
+	}{
+		{c: 0, want: 0x00},
+		{c: 1, want: 0x04},
+		{c: 16, want: 0x41},
+		{c: 32, want: 0x82},
+		{c: 63, want: 0xFF},
+	}
+	for _, g := range golden {
+		if got := expand6(g.c); got != g.want {
+			t.Errorf("expand6(%d): expected 0x%02X, got 0x%02X", g.c, g.want, got)
+		}
+	}
+}
+
+func TestPaletteChanged(t *testing.T) {
+	// The palette changes every third frame.
+	f := parseBytesAt(t, encodeFrames(t, testFrames(7, 4, 4), 100))
+	for i := 0; i < f.NFrames; i++ {
+		if _, err := f.DecodeFrame(i); err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		if got, want := f.PaletteChanged(), i%3 == 0; got != want {
+			t.Errorf("frame %d: expected palette changed %v, got %v", i, want, got)
+		}
+	}
+	// A palette record which retains every colour leaves the palette unchanged.
+	rec := palRecord(63, 0, 32, 0x80|127, 0x80|126)
+	f = parseBytes(t, buildFile(t, FileHeader{}, absentTrees, []testFrame{
+		{key: true, typ: FrameTypePaletteRecord, data: rec},
+		{typ: FrameTypePaletteRecord, data: rec},
+		{typ: FrameTypePaletteRecord, data: palRecord(1, 2, 3, 0x80|127, 0x80|126)},
+	}))
+	for i, want := range []bool{true, false, true} {
+		if _, err := f.DecodeFrame(i); err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		if got := f.PaletteChanged(); got != want {
+			t.Errorf("frame %d: expected palette changed %v, got %v", i, want, got)
+		}
+	}
+}
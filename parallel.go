@@ -0,0 +1,98 @@
+package smk
+
+import (
+	"image"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeAllParallel decodes and returns every video frame of the Smacker file,
+// excluding the ring frame, using at most workers goroutines. The frames are
+// split into segments starting at key frames, each of which is decoded
+// independently; the decoder state of f is left unaffected.
+//
+// Parallel decoding requires random access to the frame data, as provided by
+// files parsed using ParseReaderAt. The first error in frame order is returned;
+// segments following a failed segment are skipped, whereas preceding segments
+// are decoded to report any earlier error.
+func (f *File) DecodeAllParallel(workers int) ([]*image.Paletted, error) {
+	if f.ra == nil {
+		return nil, errors.New("parallel decoding requires random access to frame data")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	// Start index of each segment.
+	var segments []int
+	for i := 0; i < f.NFrames; i++ {
+		if i == 0 || f.IsKeyFrame(i) {
+			segments = append(segments, i)
+		}
+	}
+	imgs := make([]*image.Paletted, f.NFrames)
+	errs := make([]error, len(segments))
+	var mu sync.Mutex
+	// Index of the first failed segment, or len(segments) if none failed.
+	failed := len(segments)
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker decodes using separate decoder state.
+			g := f.cloneDecoder()
+			for seg := range jobs {
+				mu.Lock()
+				skip := seg > failed
+				mu.Unlock()
+				if skip {
+					continue
+				}
+				end := f.NFrames
+				if seg+1 < len(segments) {
+					end = segments[seg+1]
+				}
+				for i := segments[seg]; i < end; i++ {
+					img, err := g.DecodeFrame(i)
+					if err != nil {
+						mu.Lock()
+						errs[seg] = err
+						if seg < failed {
+							failed = seg
+						}
+						mu.Unlock()
+						break
+					}
+					imgs[i] = img
+				}
+			}
+		}()
+	}
+	for seg := range segments {
+		jobs <- seg
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return imgs, nil
+}
+
+// cloneDecoder returns a copy of f with separate decoder state, for decoding
+// frames of a random access file independently of f.
+func (f *File) cloneDecoder() *File {
+	g := *f
+	g.mmapTree = f.mmapTree.clone()
+	g.mclrTree = f.mclrTree.clone()
+	g.fullTree = f.fullTree.clone()
+	g.typeTree = f.typeTree.clone()
+	g.cur = 0
+	g.buf, g.frame, g.pal, g.prevPal = nil, nil, nil, nil
+	g.audio = [7][]byte{}
+	return &g
+}
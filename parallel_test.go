@@ -0,0 +1,90 @@
+package smk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// segmentFrames returns n frames with a key frame every 7 frames, each of which
+// has a palette record which copies entry 1 of the preceding palette to entry 0
+// and sets entry 1, except for every third frame which has no palette record.
+func segmentFrames(n int) []testFrame {
+	var frames []testFrame
+	for i := 0; i < n; i++ {
+		frame := testFrame{key: i%7 == 0}
+		if i%3 != 0 {
+			frame.typ = FrameTypePaletteRecord
+			frame.data = palRecord(0x40, 1, byte(i), 8, 9, 0x80|127, 0x80|125)
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func TestDecodeAllParallel(t *testing.T) {
+	for _, raw := range [][]byte{
+		encodeFrames(t, testFrames(20, 16, 8), 100),
+		buildFile(t, FileHeader{}, absentTrees, segmentFrames(40)),
+	} {
+		want, err := parseBytes(t, raw).DecodeAll()
+		if err != nil {
+			t.Fatalf("unable to decode frames; %+v", err)
+		}
+		f := parseBytesAt(t, raw)
+		for _, workers := range []int{0, 1, 4} {
+			imgs, err := f.DecodeAllParallel(workers)
+			if err != nil {
+				t.Fatalf("%d workers: unable to decode frames; %+v", workers, err)
+			}
+			if !reflect.DeepEqual(imgs, want) {
+				t.Errorf("%d workers: frames differ from sequential decoding", workers)
+			}
+		}
+		if f.cur != 0 {
+			t.Errorf("expected decoder state unaffected, next frame to decode is %d", f.cur)
+		}
+	}
+	f := parseBytes(t, encodeFrames(t, testFrames(2, 4, 4), 100))
+	if _, err := f.DecodeAllParallel(2); err == nil {
+		t.Error("expected error for parallel decoding without random access")
+	}
+}
+
+func TestDecodeAllParallelFirstError(t *testing.T) {
+	// Invalid palette records, copying entries beyond the palette, in the last
+	// frame of the second segment and the first frame of the fifth segment.
+	frames := segmentFrames(42)
+	for _, i := range []int{13, 28} {
+		frames[i].typ = FrameTypePaletteRecord
+		frames[i].data = palRecord(0x40|63, 255)
+	}
+	f := parseBytesAt(t, buildFile(t, FileHeader{}, absentTrees, frames))
+	for n := 0; n < 20; n++ {
+		_, err := f.DecodeAllParallel(6)
+		if err == nil {
+			t.Fatal("expected error for invalid palette record")
+		}
+		if !strings.Contains(err.Error(), "frame 13") {
+			t.Fatalf("expected error of frame 13, got %v", err)
+		}
+	}
+}
+
+func BenchmarkDecodeAll(b *testing.B) {
+	raw := encodeFrames(b, testFrames(32, 320, 200), 100)
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := parseBytesAt(b, raw).DecodeAll(); err != nil {
+				b.Fatalf("unable to decode frames; %+v", err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := parseBytesAt(b, raw).DecodeAllParallel(4); err != nil {
+				b.Fatalf("unable to decode frames; %+v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package smk
+
+import (
+	"image"
+	"time"
+)
+
+// Play decodes the remaining frames of the Smacker file in order, starting at
+// the next frame to decode. For each frame, onAudio is invoked with the decoded
+// audio data of each track present in the frame, in increasing track order,
+// followed by onFrame with the decoded video frame and its presentation time.
+//
+// The image passed to onFrame is reused between frames, and is thus only valid
+// until onFrame returns. Either callback may be nil. Playback stops at the
+// first error, either returned by a callback or encountered while decoding.
+func (f *File) Play(onFrame func(img *image.Paletted, pts time.Duration) error, onAudio func(track int, pcm []byte) error) error {
+	it := f.Frames()
+	for it.Next() {
+		i := f.cur - 1
+		if onAudio != nil {
+			for track := range f.TrackInfo {
+				if f.audio[track] == nil {
+					continue
+				}
+				pcm, err := f.DecodeAudio(track, i)
+				if err != nil {
+					return err
+				}
+				if err := onAudio(track, pcm); err != nil {
+					return err
+				}
+			}
+		}
+		if onFrame != nil {
+			if err := onFrame(it.Frame(), f.FrameTimestamp(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
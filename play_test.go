@@ -0,0 +1,88 @@
+package smk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlay(t *testing.T) {
+	samples := [][]int{{1000, -3, 200}, nil, {-1, 1234, 5, 5}}
+	hdr := FileHeader{FrameRate: 50}
+	hdr.TrackInfo[0] = testMono16
+	hdr.AudioSize[0] = 8
+	var frames []testFrame
+	for i, s := range samples {
+		frame := testFrame{key: i == 0}
+		if s != nil {
+			frame.typ = FrameTypeAudioDataTrack0
+			frame.data = audioChunk(encodeAudio(s, 1, true))
+		}
+		frames = append(frames, frame)
+	}
+	raw := buildFile(t, hdr, absentTrees, frames)
+	var events []string
+	onFrame := func(img *image.Paletted, pts time.Duration) error {
+		events = append(events, fmt.Sprintf("frame %v %v", img.Bounds().Size(), pts))
+		return nil
+	}
+	onAudio := func(track int, pcm []byte) error {
+		events = append(events, fmt.Sprintf("audio %d %d", track, len(pcm)))
+		return nil
+	}
+	if err := parseBytes(t, raw).Play(onFrame, onAudio); err != nil {
+		t.Fatalf("unable to play Smacker file; %+v", err)
+	}
+	want := []string{
+		"audio 0 6",
+		"frame (4,4) 0s",
+		"frame (4,4) 50ms",
+		"audio 0 8",
+		"frame (4,4) 100ms",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("callback mismatch; expected %q, got %q", want, events)
+	}
+
+	// Playback stops at the first error returned by a callback.
+	errStop := errors.New("stop")
+	n := 0
+	err := parseBytes(t, raw).Play(func(img *image.Paletted, pts time.Duration) error {
+		n++
+		if n == 2 {
+			return errStop
+		}
+		return nil
+	}, nil)
+	if err != errStop {
+		t.Errorf("expected error of callback, got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected playback to stop after 2 frames, got %d", n)
+	}
+
+	// Playback starts at the next frame to decode.
+	f := parseBytes(t, raw)
+	if _, err := f.DecodeFrame(0); err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	var pcm []byte
+	err = f.Play(nil, func(track int, data []byte) error {
+		pcm = append(pcm, data...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to play Smacker file; %+v", err)
+	}
+	wantPCM, err := parseBytes(t, raw).DecodeAudio(0, 2)
+	if err != nil {
+		t.Fatalf("unable to decode audio; %+v", err)
+	}
+	if !bytes.Equal(pcm, wantPCM) {
+		t.Errorf("audio mismatch of remaining frames; expected %v, got %v", wantPCM, pcm)
+	}
+}
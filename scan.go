@@ -0,0 +1,126 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ScanForSMK returns the offsets of embedded Smacker files within the first
+// size bytes of r.
+//
+// An offset is reported when a "SMK2" or "SMK4" signature is followed by a
+// plausible file header; i.e. non-zero frame dimensions, at least one frame,
+// and a frame size table, Huffman trees and frame data which all fit within
+// size. Scanning resumes at the end of each plausible file, so signatures
+// within its frame data are not reported.
+func ScanForSMK(r io.ReaderAt, size int64) ([]int64, error) {
+	const chunkSize = 64 * 1024
+	var offsets []int64
+	buf := make([]byte, chunkSize+3)
+	for base := int64(0); base < size; {
+		n, err := r.ReadAt(buf[:min64(int64(len(buf)), size-base)], base)
+		if err != nil && err != io.EOF {
+			return offsets, errors.WithStack(err)
+		}
+		if n < 4 {
+			break
+		}
+		next := base + int64(n) - 3
+		for i := 0; i+4 <= n; i++ {
+			sig := buf[i : i+4]
+			if !bytes.Equal(sig, []byte("SMK2")) && !bytes.Equal(sig, []byte("SMK4")) {
+				continue
+			}
+			off := base + int64(i)
+			end, ok, err := plausibleSMK(r, off, size)
+			if err != nil {
+				return offsets, err
+			}
+			if !ok {
+				continue
+			}
+			offsets = append(offsets, off)
+			next = end
+			break
+		}
+		base = next
+	}
+	return offsets, nil
+}
+
+// SplitConcatenated splits the first size bytes of r into the Smacker files
+// stored back-to-back within, as located by ScanForSMK. Each section extends
+// from the start of a Smacker file to the start of the next, or to size for the
+// last one.
+func SplitConcatenated(r io.ReaderAt, size int64) ([]*io.SectionReader, error) {
+	offsets, err := ScanForSMK(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 {
+		return nil, errors.New("unable to locate Smacker file; no valid signature with plausible header")
+	}
+	var sections []*io.SectionReader
+	for i, off := range offsets {
+		end := size
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		sections = append(sections, io.NewSectionReader(r, off, end-off))
+	}
+	return sections, nil
+}
+
+// plausibleSMK reports whether the Smacker file header at offset off of r is
+// plausible, given that r holds size bytes. If plausible, the offset of the end
+// of the Smacker file is returned.
+func plausibleSMK(r io.ReaderAt, off, size int64) (end int64, ok bool, err error) {
+	if size-off < headerSize {
+		return 0, false, nil
+	}
+	var hdr [headerSize]byte
+	if _, err := r.ReadAt(hdr[:], off); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	width := binary.LittleEndian.Uint32(hdr[4:])
+	height := binary.LittleEndian.Uint32(hdr[8:])
+	nframes := int64(binary.LittleEndian.Uint32(hdr[12:]))
+	flags := Flag(binary.LittleEndian.Uint32(hdr[20:]))
+	treesSize := int64(binary.LittleEndian.Uint32(hdr[52:]))
+	if width == 0 || height == 0 || nframes == 0 {
+		return 0, false, nil
+	}
+	// The ring frame has an entry in the frame size and type tables.
+	if flags.HasRingFrame() {
+		nframes++
+	}
+	// Frame size and type tables, and Huffman trees.
+	dataStart := off + headerSize + 5*nframes + treesSize
+	if dataStart > size {
+		return 0, false, nil
+	}
+	sizes := make([]byte, 4*nframes)
+	if _, err := r.ReadAt(sizes, off+headerSize); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	end = dataStart
+	for i := int64(0); i < nframes; i++ {
+		// Clear bit 0 and 1 to get the proper frame size.
+		end += int64(binary.LittleEndian.Uint32(sizes[4*i:]) &^ 3)
+		if end > size {
+			return 0, false, nil
+		}
+	}
+	return end, true, nil
+}
+
+// min64 returns the smaller of a and b.
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,97 @@
+package smk
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestScanForSMK(t *testing.T) {
+	const chunkSize = 64 * 1024
+	raw := encodeFrames(t, testFrames(2, 8, 4), 100)
+	// Signature followed by an implausible header with zero frame dimensions.
+	bogus := append([]byte("SMK2"), make([]byte, headerSize)...)
+	// File embedding the Smacker file within its frame data.
+	outer := buildFile(t, FileHeader{}, absentTrees, []testFrame{{key: true, data: raw}})
+	cat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	golden := []struct {
+		name string
+		data []byte
+		want []int64
+	}{
+		{name: "single", data: raw, want: []int64{0}},
+		{name: "back-to-back", data: cat(raw, raw, raw), want: []int64{0, int64(len(raw)), 2 * int64(len(raw))}},
+		{name: "implausible header", data: cat(bogus, raw), want: []int64{int64(len(bogus))}},
+		// The file is truncated, so its frame data does not fit.
+		{name: "truncated", data: cat(raw, raw[:len(raw)-1]), want: []int64{0}},
+		// Signatures within the frame data of a file are not reported.
+		{name: "embedded", data: cat(outer, raw), want: []int64{0, int64(len(outer))}},
+		{name: "none", data: make([]byte, 100), want: nil},
+	}
+	check := func(name string, data []byte, want []int64) {
+		t.Helper()
+		got, err := ScanForSMK(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Errorf("%s: unable to scan for Smacker files; %+v", name, err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: offsets mismatch; expected %v, got %v", name, want, got)
+		}
+	}
+	for _, g := range golden {
+		check(g.name, g.data, g.want)
+	}
+	// The signature straddles the boundary between chunks of the scan.
+	for _, pad := range []int{chunkSize - 4, chunkSize - 3, chunkSize - 2, chunkSize - 1, chunkSize} {
+		check(fmt.Sprintf("signature at offset %d", pad), cat(make([]byte, pad), raw), []int64{int64(pad)})
+	}
+}
+
+func TestSplitConcatenated(t *testing.T) {
+	a := encodeFrames(t, testFrames(2, 8, 4), 100)
+	b := encodeFrames(t, testFrames(3, 4, 4), 50)
+	garbage := []byte("garbage SMK4 prefix")
+	partial := bytes.Join([][]byte{a, b[:len(b)-4]}, nil)
+	golden := []struct {
+		name string
+		data []byte
+		want [][]byte
+	}{
+		{name: "two files", data: bytes.Join([][]byte{a, b}, nil), want: [][]byte{a, b}},
+		// A trailing partial file is part of the section of the preceding file.
+		{name: "trailing partial file", data: partial, want: [][]byte{partial}},
+		// A garbage prefix is skipped.
+		{name: "garbage prefix", data: bytes.Join([][]byte{garbage, a, b}, nil), want: [][]byte{a, b}},
+	}
+	for _, g := range golden {
+		sections, err := SplitConcatenated(bytes.NewReader(g.data), int64(len(g.data)))
+		if err != nil {
+			t.Errorf("%s: unable to split Smacker files; %+v", g.name, err)
+			continue
+		}
+		if len(sections) != len(g.want) {
+			t.Errorf("%s: expected %d sections, got %d", g.name, len(g.want), len(sections))
+			continue
+		}
+		for i, sr := range sections {
+			got := make([]byte, sr.Size())
+			if _, err := sr.ReadAt(got, 0); err != nil {
+				t.Fatalf("%s: section %d: unable to read section; %v", g.name, i, err)
+			}
+			if !bytes.Equal(got, g.want[i]) {
+				t.Errorf("%s: section %d: contents mismatch", g.name, i)
+			}
+		}
+		// Each complete section parses as a Smacker file.
+		if _, err := ParseReaderAt(sections[0], sections[0].Size()); err != nil {
+			t.Errorf("%s: unable to parse first section; %+v", g.name, err)
+		}
+	}
+	if _, err := SplitConcatenated(bytes.NewReader(garbage), int64(len(garbage))); err == nil {
+		t.Error("expected error for data without Smacker files")
+	}
+}
@@ -6,6 +6,7 @@ package smk
 
 import (
 	"bufio"
+	"image/color"
 	"io"
 	"os"
 
@@ -17,10 +18,57 @@ type File struct {
 	// File header.
 	FileHeader
 
+	// Huffman trees as stored on disk.
+	trees []byte
+	// Mono blocks maps Huffman tree.
+	mmapTree *HuffmanTree
+	// Mono blocks colours Huffman tree.
+	mclrTree *HuffmanTree
+	// Full blocks Huffman tree.
+	fullTree *HuffmanTree
+	// Block type descriptors Huffman tree.
+	typeTree *HuffmanTree
+
+	// Offset of each frame, followed by the end offset of the frame data.
+	offsets []int64
+	// Size of the Smacker file in bytes if known, and -1 otherwise.
+	size int64
+
+	// Index of the next frame to decode.
+	cur int
+	// Error reported for every subsequent decoding of frames, once the frame
+	// buffer is out of sync with the frames read by sequential decoding; or nil.
+	err error
+	// Frame data buffer of the current frame.
+	buf []byte
+	// Number of bytes of r to skip before reading the next frame; i.e. the
+	// frame data of frames decoded by DecodeFrameBytes.
+	skip int64
+	// Frame buffer of the most recently decoded frame; one palette index per
+	// pixel.
+	frame []uint8
+	// Current palette.
+	pal color.Palette
+	// Palette of the preceding frame; scratch buffer of palette decoding.
+	prevPal color.Palette
+	// Colour most recently set at each palette entry by a palette record.
+	colors [256]color.Color
+	// Palette changed by the most recently decoded frame.
+	palChanged bool
+	// Audio data of each track in the most recently decoded frame, or nil if
+	// not present; slices of buf.
+	audio [7][]byte
+
 	// Underlying io.Reader.
 	r io.Reader
+	// Underlying io.ReaderAt for random access to frame data if present, and
+	// nil otherwise.
+	ra io.ReaderAt
 	// Underlying io.Closer of reader if present, and nil otherwise.
 	c io.Closer
+
+	// Pixel aspect ratio; the zero value denotes square pixels.
+	aspectNum, aspectDen int
 }
 
 // Parse returns a new File for accessing the video and audio tracks of r.
@@ -28,17 +76,118 @@ type File struct {
 // It reads and parses the Smacker file header, the frame size and type
 // information, and the Huffman decoding tables, but skips all frame data.
 func Parse(r io.Reader) (*File, error) {
-	// Parse file header.
-	f := &File{
-		r: bufio.NewReader(r),
+	f := &File{}
+	if err := f.parse(r); err != nil {
+		return nil, err
 	}
+	return f, nil
+}
+
+// Reset closes the underlying reader of f if it implements io.Closer, and
+// parses r into f for accessing the video and audio tracks of r, as for Parse.
+// The frame buffers and Huffman tree nodes of f are reused where their capacity
+// allows, whereas all other state, including the file header, is discarded.
+//
+// If r cannot be parsed, f is left in an unusable state until the next
+// successful call to Reset.
+func (f *File) Reset(r io.Reader) error {
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	*f = File{
+		mmapTree: f.mmapTree,
+		mclrTree: f.mclrTree,
+		fullTree: f.fullTree,
+		typeTree: f.typeTree,
+		buf:      f.buf[:0],
+		frame:    f.frame[:0],
+		pal:      f.pal[:0],
+		prevPal:  f.prevPal,
+		colors:   f.colors,
+	}
+	return f.parse(r)
+}
+
+// parse parses the Smacker file of r into f; the Huffman trees and buffers of f
+// are reused if present.
+func (f *File) parse(r io.Reader) error {
+	// Parse file header.
+	f.r = bufio.NewReader(r)
+	f.size = -1
 	if c, ok := r.(io.Closer); ok {
 		f.c = c
 	}
+	// Record start offset of seekable sources, to measure the file size.
+	s, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = s.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
 	if err := f.parseFileHeader(); err != nil {
+		return err
+	}
+	// Parse Huffman decoding tables.
+	if err := f.parseHuffmanTrees(); err != nil {
+		return err
+	}
+	f.indexFrames()
+	if seekable {
+		if err := f.measureSize(s, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// measureSize records the size of the Smacker file of the seekable source s,
+// which started at offset start, and restores the read position of s.
+func (f *File) measureSize(s io.Seeker, start int64) error {
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := s.Seek(pos, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	f.size = end - start
+	return nil
+}
+
+// readN reads and returns n bytes from r. The buffer grows as data is read, so
+// that sizes read from corrupt files do not cause excessive allocations.
+func readN(r io.Reader, n int64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if int64(len(buf)) != n {
+		return nil, errors.WithStack(io.ErrUnexpectedEOF)
+	}
+	return buf, nil
+}
+
+// ParseReaderAt returns a new File for random access to the video and audio
+// tracks of r, where size is the size of the Smacker file in bytes.
+//
+// It reads and parses the Smacker file header, the frame size and type
+// information, and the Huffman decoding tables. Frame data is read directly
+// from r as frames are decoded, which allows frames to be decoded in any order.
+func ParseReaderAt(r io.ReaderAt, size int64) (*File, error) {
+	f, err := Parse(io.NewSectionReader(r, 0, size))
+	if err != nil {
 		return nil, err
 	}
-	// TODO: Parse Huffman decoding tables.
+	if c, ok := r.(io.Closer); ok {
+		f.c = c
+	}
+	f.ra = r
 	return f, nil
 }
 
@@ -48,11 +197,25 @@ func Parse(r io.Reader) (*File, error) {
 // It reads and parses the Smacker file header, the frame size and type
 // information, and the Huffman decoding tables, but skips all frame data.
 func ParseFile(path string) (*File, error) {
-	f, err := os.Open(path)
+	fd, err := os.Open(path)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return Parse(f)
+	f, err := Parse(fd)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// MustParseFile is like ParseFile but panics if the file cannot be parsed.
+func MustParseFile(path string) *File {
+	f, err := ParseFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return f
 }
 
 // Close closes the underlying reader if it implements io.Closer, and performs
@@ -63,3 +226,36 @@ func (f *File) Close() error {
 	}
 	return nil
 }
+
+// PixelAspectRatio returns the pixel aspect ratio of the video as the width to
+// height ratio num:den of a single pixel. Smacker files do not store the pixel
+// aspect ratio, so it is 1:1 unless set by SetPixelAspectRatio.
+func (f *File) PixelAspectRatio() (num, den int) {
+	if f.aspectNum == 0 || f.aspectDen == 0 {
+		return 1, 1
+	}
+	return f.aspectNum, f.aspectDen
+}
+
+// SetPixelAspectRatio sets the pixel aspect ratio of the video to num:den, e.g.
+// 5:6 for 320x200 content displayed at 4:3; the ratio is stored in lowest
+// terms. Non-positive values reset the pixel aspect ratio to 1:1.
+//
+// ToGIF and WriteGIF correct non-square pixels by scaling frames horizontally
+// to a width of Width*num/den pixels, using nearest-neighbour sampling.
+func (f *File) SetPixelAspectRatio(num, den int) {
+	if num <= 0 || den <= 0 {
+		f.aspectNum, f.aspectDen = 0, 0
+		return
+	}
+	d := gcd(num, den)
+	f.aspectNum, f.aspectDen = num/d, den/d
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,148 @@
+package smk
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.smk")
+	if err := os.WriteFile(path, encodeFrames(t, testFrames(2, 8, 4), 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("unable to parse %q; %+v", path, err)
+	}
+	defer f.Close()
+	if f.Width != 8 || f.Height != 4 || f.NFrames != 2 {
+		t.Errorf("header mismatch; expected 8x4 with 2 frames, got %dx%d with %d frames", f.Width, f.Height, f.NFrames)
+	}
+}
+
+func TestParseFileCloseOnError(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("unable to count open file descriptors; %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "invalid.smk")
+	if err := os.WriteFile(path, []byte("SMK9 invalid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := ParseFile(path); err == nil {
+			t.Fatalf("expected error for invalid file %q", path)
+		}
+	}
+	after, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) > len(fds) {
+		t.Errorf("file descriptor leak; %d open before, %d open after", len(fds), len(after))
+	}
+}
+
+func TestMustParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.smk")
+	if err := os.WriteFile(path, encodeFrames(t, testFrames(1, 4, 4), 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := MustParseFile(path)
+	f.Close()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for missing file")
+		}
+	}()
+	MustParseFile(filepath.Join(t.TempDir(), "missing.smk"))
+}
+
+// closeCounter is an io.ReadCloser which counts the calls to Close.
+type closeCounter struct {
+	*bytes.Reader
+	n int
+}
+
+func (r *closeCounter) Close() error {
+	r.n++
+	return nil
+}
+
+func TestReset(t *testing.T) {
+	imgs1 := testFrames(3, 8, 8)
+	imgs2 := testFrames(2, 4, 4)
+	// Frames of the second file use a palette not used by the first file.
+	for _, img := range imgs2 {
+		img.Palette = testPalette(7)
+	}
+	r1 := &closeCounter{Reader: bytes.NewReader(encodeFrames(t, imgs1, 100))}
+	f, err := Parse(r1)
+	if err != nil {
+		t.Fatalf("unable to parse Smacker file; %+v", err)
+	}
+	for i := range imgs1 {
+		if _, err := f.DecodeFrame(i); err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+	}
+	if err := f.Reset(bytes.NewReader(encodeFrames(t, imgs2, 50))); err != nil {
+		t.Fatalf("unable to reset File; %+v", err)
+	}
+	if r1.n != 1 {
+		t.Errorf("expected first reader closed once, got %d", r1.n)
+	}
+	if f.Width != 4 || f.Height != 4 || f.NFrames != 2 || f.FrameRate != 50 {
+		t.Errorf("header mismatch; expected 4x4 with 2 frames at rate 50, got %dx%d with %d frames at rate %d", f.Width, f.Height, f.NFrames, f.FrameRate)
+	}
+	if pal := f.Palette(); pal != nil {
+		t.Errorf("expected nil palette before decoding, got %d entries", len(pal))
+	}
+	for i, want := range imgs2 {
+		img, err := f.DecodeFrame(i)
+		if err != nil {
+			t.Fatalf("frame %d: unable to decode frame; %+v", i, err)
+		}
+		if !bytes.Equal(img.Pix, want.Pix) {
+			t.Errorf("frame %d: pixel mismatch", i)
+		}
+		for j := range want.Palette {
+			if img.Palette[j] != want.Palette[j] {
+				t.Errorf("frame %d: palette entry %d mismatch; expected %v, got %v", i, j, want.Palette[j], img.Palette[j])
+				break
+			}
+		}
+	}
+}
+
+func TestPixelAspectRatio(t *testing.T) {
+	golden := []struct {
+		num, den         int
+		wantNum, wantDen int
+	}{
+		{num: 5, den: 6, wantNum: 5, wantDen: 6},
+		// Ratios are reduced to lowest terms.
+		{num: 10, den: 12, wantNum: 5, wantDen: 6},
+		{num: 4, den: 4, wantNum: 1, wantDen: 1},
+		// Non-positive values reset the ratio to 1:1.
+		{num: 0, den: 6, wantNum: 1, wantDen: 1},
+		{num: 5, den: -6, wantNum: 1, wantDen: 1},
+	}
+	f := parseBytes(t, encodeFrames(t, testFrames(1, 8, 4), 100))
+	for _, g := range golden {
+		f.SetPixelAspectRatio(3, 2)
+		f.SetPixelAspectRatio(g.num, g.den)
+		if num, den := f.PixelAspectRatio(); num != g.wantNum || den != g.wantDen {
+			t.Errorf("%d:%d: pixel aspect ratio mismatch; expected %d:%d, got %d:%d", g.num, g.den, g.wantNum, g.wantDen, num, den)
+		}
+	}
+	// Y-doubled and Y-interlaced videos have square pixels unless set.
+	for _, flags := range []Flag{FlagYDoubled, FlagYInterlaced} {
+		f := parseBytes(t, buildFile(t, FileHeader{Flags: flags}, absentTrees, []testFrame{{key: true}}))
+		if num, den := f.PixelAspectRatio(); num != 1 || den != 1 {
+			t.Errorf("flags 0x%02X: pixel aspect ratio mismatch; expected 1:1, got %d:%d", flags, num, den)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package smk
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ResampleTimeline returns the sequence of source frame indices to emit at each
+// tick of a constant targetFPS timeline, duplicating or dropping frames as
+// needed to retain the playback duration of the file.
+func (f *File) ResampleTimeline(targetFPS float64) ([]int, error) {
+	if !(targetFPS > 0) || math.IsInf(targetFPS, 1) {
+		return nil, errors.Errorf("invalid target frame rate; expected positive finite value, got %v", targetFPS)
+	}
+	if f.NFrames <= 0 {
+		return nil, nil
+	}
+	srcFPS := f.FrameRate.FPS()
+	// Number of target ticks covering the duration of the file.
+	n := int(math.Round(float64(f.NFrames) * targetFPS / srcFPS))
+	if n < 1 {
+		n = 1
+	}
+	indices := make([]int, n)
+	for tick := range indices {
+		// Source frame displayed at the presentation time of the tick; the
+		// epsilon guards against rounding down exact frame boundaries.
+		i := int(math.Floor(float64(tick)*srcFPS/targetFPS + 1e-9))
+		if i >= f.NFrames {
+			i = f.NFrames - 1
+		}
+		indices[tick] = i
+	}
+	return indices, nil
+}
+
+// EffectiveFrameDurations returns the display duration of each unique frame.
+//
+// Encoders may express variable frame timing by emitting duplicate frames of
+// zero size, each of which extends the display time of the preceding frame by
+// one frame period. Such zero-size frames are coalesced into the duration of
+// the preceding unique frame; a zero-size first frame is treated as unique.
+func (f *File) EffectiveFrameDurations() []time.Duration {
+	period := f.FrameRate.period()
+	var durations []time.Duration
+	for i := 0; i < f.NFrames; i++ {
+		if f.FrameLen(i) == 0 && len(durations) > 0 {
+			durations[len(durations)-1] += period
+			continue
+		}
+		durations = append(durations, period)
+	}
+	return durations
+}
+
+// Duration returns the total playback duration of the video, excluding the ring
+// frame.
+func (f *File) Duration() time.Duration {
+	return time.Duration(f.NFrames) * f.FrameRate.period()
+}
+
+// FrameTimestamp returns the presentation time of the i-th frame, relative to
+// the start of the video.
+func (f *File) FrameTimestamp(i int) time.Duration {
+	return time.Duration(i) * f.FrameRate.period()
+}
+
+// FrameDelay returns the display duration of the i-th frame, or 0 if i is out
+// of range. The frame delays of all frames sum to Duration.
+func (f *File) FrameDelay(i int) time.Duration {
+	if i < 0 || i >= f.NFrames {
+		return 0
+	}
+	return f.FrameRate.period()
+}
+
+// period returns the display duration of a single frame.
+//
+// The frame period is specified in milliseconds for positive frame rates, and in
+// units of 10 microseconds for negative frame rates; i.e. consistent with FPS.
+func (rate FrameRate) period() time.Duration {
+	switch {
+	case rate > 0:
+		return time.Duration(rate) * time.Millisecond
+	case rate < 0:
+		return time.Duration(-int64(rate)) * 10 * time.Microsecond
+	default:
+		// 10 frames per second.
+		return 100 * time.Millisecond
+	}
+}
@@ -0,0 +1,158 @@
+package smk
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	golden := []struct {
+		rate    FrameRate
+		nframes int
+		fps     float64
+		want    time.Duration
+		ts2     time.Duration
+	}{
+		// 1000 / 50 = 20 fps.
+		{rate: 50, nframes: 10, fps: 20, want: 500 * time.Millisecond, ts2: 100 * time.Millisecond},
+		// 100000 / 6667 = 15 fps.
+		{rate: -6667, nframes: 30, fps: 100000.0 / 6667, want: 30 * 66670 * time.Microsecond, ts2: 2 * 66670 * time.Microsecond},
+		// 10 fps.
+		{rate: 0, nframes: 25, fps: 10, want: 2500 * time.Millisecond, ts2: 200 * time.Millisecond},
+	}
+	for _, g := range golden {
+		f := &File{FileHeader: FileHeader{FrameRate: g.rate, NFrames: g.nframes}}
+		if got := f.FrameRate.FPS(); got != g.fps {
+			t.Errorf("rate %d: FPS mismatch; expected %v, got %v", g.rate, g.fps, got)
+		}
+		if got := f.Duration(); got != g.want {
+			t.Errorf("rate %d: duration mismatch; expected %v, got %v", g.rate, g.want, got)
+		}
+		if got := f.FrameTimestamp(0); got != 0 {
+			t.Errorf("rate %d: expected zero timestamp of frame 0, got %v", g.rate, got)
+		}
+		if got := f.FrameTimestamp(2); got != g.ts2 {
+			t.Errorf("rate %d: timestamp of frame 2 mismatch; expected %v, got %v", g.rate, g.ts2, got)
+		}
+		if got := f.FrameTimestamp(g.nframes); got != g.want {
+			t.Errorf("rate %d: timestamp past the last frame mismatch; expected %v, got %v", g.rate, g.want, got)
+		}
+	}
+}
+
+func TestFrameDelay(t *testing.T) {
+	golden := []struct {
+		rate  FrameRate
+		delay time.Duration
+	}{
+		{rate: 50, delay: 50 * time.Millisecond},
+		{rate: -6667, delay: 66670 * time.Microsecond},
+		{rate: 0, delay: 100 * time.Millisecond},
+	}
+	for _, g := range golden {
+		f := &File{FileHeader: FileHeader{FrameRate: g.rate, NFrames: 7}}
+		var sum time.Duration
+		for i := 0; i < f.NFrames; i++ {
+			delay := f.FrameDelay(i)
+			if delay != g.delay {
+				t.Errorf("rate %d: frame %d: delay mismatch; expected %v, got %v", g.rate, i, g.delay, delay)
+			}
+			// Each frame is displayed until the presentation time of the next.
+			if got, want := f.FrameTimestamp(i)+delay, f.FrameTimestamp(i+1); got != want {
+				t.Errorf("rate %d: frame %d: end of display mismatch; expected %v, got %v", g.rate, i, want, got)
+			}
+			sum += delay
+		}
+		if sum != f.Duration() {
+			t.Errorf("rate %d: expected frame delays to sum to %v, got %v", g.rate, f.Duration(), sum)
+		}
+		for _, i := range []int{-1, f.NFrames} {
+			if got := f.FrameDelay(i); got != 0 {
+				t.Errorf("rate %d: expected zero delay of frame %d out of range, got %v", g.rate, i, got)
+			}
+		}
+	}
+}
+
+func TestResampleTimeline(t *testing.T) {
+	golden := []struct {
+		rate      FrameRate
+		nframes   int
+		targetFPS float64
+		want      []int
+	}{
+		// 10 fps to 20 fps; every frame is duplicated.
+		{rate: 100, nframes: 3, targetFPS: 20, want: []int{0, 0, 1, 1, 2, 2}},
+		// 20 fps to 10 fps; every other frame is dropped.
+		{rate: 50, nframes: 6, targetFPS: 10, want: []int{0, 2, 4}},
+		// 100000 / 4000 = 25 fps to 50 fps.
+		{rate: -4000, nframes: 3, targetFPS: 50, want: []int{0, 0, 1, 1, 2, 2}},
+		// 100000 / 6667 ~= 14.9993 fps to 30 fps; frame 1 is presented at
+		// 66.67 ms, just after the tick at 66.667 ms.
+		{rate: -6667, nframes: 4, targetFPS: 30, want: []int{0, 0, 0, 1, 1, 2, 2, 3}},
+		// 10 fps to 15 fps.
+		{rate: 0, nframes: 4, targetFPS: 15, want: []int{0, 0, 1, 2, 2, 3}},
+		// Identical frame rate.
+		{rate: 40, nframes: 3, targetFPS: 25, want: []int{0, 1, 2}},
+	}
+	for _, g := range golden {
+		f := &File{FileHeader: FileHeader{FrameRate: g.rate, NFrames: g.nframes}}
+		got, err := f.ResampleTimeline(g.targetFPS)
+		if err != nil {
+			t.Errorf("rate %d to %v fps: unable to resample timeline; %+v", g.rate, g.targetFPS, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("rate %d to %v fps: frame index mismatch; expected %v, got %v", g.rate, g.targetFPS, g.want, got)
+		}
+	}
+	f := &File{FileHeader: FileHeader{FrameRate: 100, NFrames: 3}}
+	for _, fps := range []float64{0, -30, math.Inf(1), math.NaN()} {
+		if _, err := f.ResampleTimeline(fps); err == nil {
+			t.Errorf("expected error for target frame rate %v", fps)
+		}
+	}
+}
+
+func TestEffectiveFrameDurations(t *testing.T) {
+	const ms = time.Millisecond
+	const dup = -1
+	golden := []struct {
+		rate  FrameRate
+		flags Flag
+		// Frame sizes; dup denotes a zero-size duplicate frame.
+		frames []int
+		want   []time.Duration
+	}{
+		{rate: 100, frames: []int{4, 4, 4}, want: []time.Duration{100 * ms, 100 * ms, 100 * ms}},
+		// -5000 denotes 50 ms per frame. A zero-size first frame is unique.
+		{rate: -5000, frames: []int{dup, 4, dup, dup, 4}, want: []time.Duration{50 * ms, 150 * ms, 50 * ms}},
+		// A zero-size ring frame does not extend the last frame.
+		{rate: -5000, flags: FlagRingFrame, frames: []int{4, dup, 4, dup}, want: []time.Duration{100 * ms, 50 * ms}},
+		{rate: 0, frames: []int{4, dup}, want: []time.Duration{200 * ms}},
+	}
+	for _, g := range golden {
+		var frames []testFrame
+		for i, size := range g.frames {
+			frame := testFrame{key: i == 0}
+			if size != dup {
+				frame.data = make([]byte, size)
+			}
+			frames = append(frames, frame)
+		}
+		f := parseBytes(t, buildFile(t, FileHeader{FrameRate: g.rate, Flags: g.flags}, absentTrees, frames))
+		got := f.EffectiveFrameDurations()
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("rate %d, frames %v: durations mismatch; expected %v, got %v", g.rate, g.frames, g.want, got)
+		}
+		var total time.Duration
+		for _, d := range got {
+			total += d
+		}
+		if total != f.Duration() {
+			t.Errorf("rate %d, frames %v: total duration mismatch; expected %v, got %v", g.rate, g.frames, f.Duration(), total)
+		}
+	}
+}
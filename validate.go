@@ -0,0 +1,91 @@
+package smk
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Errors reported by Validate and by the parsing and decoding of truncated
+// files. The returned errors wrap these, so the cause may be determined using
+// errors.Cause or errors.Is.
+var (
+	// ErrInvalidDimensions is reported for zero or overly large frame
+	// dimensions.
+	ErrInvalidDimensions = errors.New("invalid frame dimensions")
+	// ErrNoFrames is reported for files without frames.
+	ErrNoFrames = errors.New("no frames present")
+	// ErrInvalidTreeSize is reported for Huffman tree sizes inconsistent with
+	// the Huffman trees stored in the file.
+	ErrInvalidTreeSize = errors.New("invalid Huffman tree size")
+	// ErrInvalidAudioSize is reported for overly large unpacked audio sizes.
+	ErrInvalidAudioSize = errors.New("invalid unpacked audio size")
+	// ErrTruncatedTrees is reported for files ending within the Huffman trees.
+	ErrTruncatedTrees = errors.New("truncated Huffman trees")
+	// ErrTruncatedFrameData is reported for files ending within the frame
+	// data; by Validate, and on decoding of the frame.
+	ErrTruncatedFrameData = errors.New("truncated frame data")
+	// ErrInvalidHuffmanCode is reported for Huffman codes which do not reach a
+	// leaf of the tree before the end of the bitstream, and for Huffman trees
+	// exceeding the limits on their number of nodes or depth.
+	ErrInvalidHuffmanCode = errors.New("invalid Huffman code")
+)
+
+// isEOF reports whether the cause of err is a premature end of input.
+func isEOF(err error) bool {
+	cause := errors.Cause(err)
+	return cause == io.EOF || cause == io.ErrUnexpectedEOF
+}
+
+// Limits of header-derived sizes.
+const (
+	// Maximum width and height of frames.
+	maxDimension = 4096
+	// Maximum allocation size in bytes of 16-bit Huffman trees.
+	maxTreeSize = 1<<28 - 1
+)
+
+// Validate checks the invariants of the sizes stored in the file header, to
+// detect corrupt or truncated files before decoding. The end of the frame data
+// is checked against the file size if known; i.e. for seekable sources.
+func (f *File) Validate() error {
+	if f.Width == 0 || f.Height == 0 || f.Width > maxDimension || f.Height > maxDimension {
+		return errors.Wrapf(ErrInvalidDimensions, "expected 1 <= width, height <= %d, got %dx%d", maxDimension, f.Width, f.Height)
+	}
+	if f.NFrames <= 0 {
+		return errors.WithStack(ErrNoFrames)
+	}
+	// The allocation sizes bound the number of nodes of each tree, whereas the
+	// trees size is the size of the encoded trees; thus, the two are unrelated
+	// in general. The encoded trees hold at least the presence bit of each of
+	// the four trees.
+	if f.TreesSize < 1 {
+		return errors.Wrapf(ErrInvalidTreeSize, "expected trees size >= 1, got %d", f.TreesSize)
+	}
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{name: "mono blocks maps", size: f.MMapSize},
+		{name: "mono blocks colours", size: f.MClrSize},
+		{name: "full blocks", size: f.FullSize},
+		{name: "block type descriptors", size: f.TypeSize},
+	}
+	for _, s := range sizes {
+		if s.size > maxTreeSize {
+			return errors.Wrapf(ErrInvalidTreeSize, "expected %s tree size <= %d, got %d", s.name, maxTreeSize, s.size)
+		}
+	}
+	for track, info := range f.TrackInfo {
+		if info.HasAudioData() && f.AudioSize[track] > maxAudioSize {
+			return errors.Wrapf(ErrInvalidAudioSize, "expected unpacked audio size of track %d <= %d, got %d", track, maxAudioSize, f.AudioSize[track])
+		}
+	}
+	if f.size != -1 && len(f.offsets) > 0 {
+		end := f.offsets[len(f.offsets)-1]
+		if end > f.size {
+			return errors.Wrapf(ErrTruncatedFrameData, "frame data ends at offset %d, beyond file size %d", end, f.size)
+		}
+	}
+	return nil
+}
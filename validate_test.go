@@ -0,0 +1,104 @@
+package smk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// readerOnly hides all methods of the underlying reader except Read; e.g. to
+// parse a bytes.Reader as a non-seekable source.
+type readerOnly struct {
+	io.Reader
+}
+
+func TestTruncated(t *testing.T) {
+	raw := encodeFrames(t, testFrames(3, 8, 8), 100)
+	f := parseBytes(t, raw)
+	tablesAt := headerSize
+	treesAt := tablesAt + 5*f.NFrames
+	if f.TreesSize < 2 {
+		t.Fatalf("expected Huffman trees of at least 2 bytes, got %d", f.TreesSize)
+	}
+	// Files ending within the header, the frame tables and the Huffman trees
+	// are reported by Parse.
+	for _, g := range []struct {
+		name string
+		n    int
+		want error
+	}{
+		{name: "header", n: headerSize / 2},
+		{name: "frame size table", n: tablesAt + 2},
+		{name: "frame type table", n: treesAt - 1},
+		{name: "Huffman trees", n: treesAt + f.TreesSize - 1, want: ErrTruncatedTrees},
+	} {
+		_, err := Parse(bytes.NewReader(raw[:g.n]))
+		if err == nil {
+			t.Errorf("%s: expected error for file truncated to %d bytes", g.name, g.n)
+			continue
+		}
+		if g.want != nil && !errors.Is(err, g.want) {
+			t.Errorf("%s: expected %v, got %v", g.name, g.want, err)
+		}
+	}
+	// Files ending within the frame data are reported by Validate if the file
+	// size is known, and on decoding of the truncated frame.
+	for _, n := range []int{int(f.FrameOffset(1)) + 3, len(raw) - 1} {
+		trunc := raw[:n]
+		f := parseBytes(t, trunc)
+		if err := f.Validate(); !errors.Is(err, ErrTruncatedFrameData) {
+			t.Errorf("file truncated to %d bytes: expected ErrTruncatedFrameData by Validate, got %v", n, err)
+		}
+		sequential, err := Parse(readerOnly{bytes.NewReader(trunc)})
+		if err != nil {
+			t.Fatalf("unable to parse Smacker file; %+v", err)
+		}
+		for _, f := range []*File{f, parseBytesAt(t, trunc), sequential} {
+			if _, err := f.DecodeAll(); !errors.Is(err, ErrTruncatedFrameData) {
+				t.Errorf("file truncated to %d bytes: expected ErrTruncatedFrameData on decoding, got %v", n, err)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	frames := []testFrame{{key: true}}
+	golden := []struct {
+		name string
+		hdr  FileHeader
+		// Frames; one key frame if nil.
+		frames []testFrame
+		want   error
+	}{
+		{name: "valid", hdr: FileHeader{}},
+		{name: "zero width", hdr: FileHeader{Width: 0, Height: 4}, want: ErrInvalidDimensions},
+		{name: "no frames", hdr: FileHeader{}, frames: []testFrame{}, want: ErrNoFrames},
+		{name: "tree size", hdr: FileHeader{FullSize: maxTreeSize + 1}, want: ErrInvalidTreeSize},
+		{name: "audio size", hdr: FileHeader{TrackInfo: [7]TrackInfo{testMono16}, AudioSize: [7]int{maxAudioSize + 1}}, want: ErrInvalidAudioSize},
+	}
+	for _, g := range golden {
+		fs := g.frames
+		if fs == nil {
+			fs = frames
+		}
+		// Invalid frame dimensions are reported by Parse, and all other
+		// invalid sizes by Validate.
+		f, err := Parse(bytes.NewReader(buildFile(t, g.hdr, absentTrees, fs)))
+		if err == nil {
+			err = f.Validate()
+		}
+		switch {
+		case g.want == nil && err != nil:
+			t.Errorf("%s: unexpected error; %+v", g.name, err)
+		case g.want != nil && !errors.Is(err, g.want):
+			t.Errorf("%s: expected %v, got %v", g.name, g.want, err)
+		}
+	}
+	// Files without frame offsets, e.g. the zero value with valid header
+	// fields, do not panic.
+	f := &File{FileHeader: FileHeader{Width: 4, Height: 4, NFrames: 1, TreesSize: 1}}
+	if err := f.Validate(); err != nil {
+		t.Errorf("unexpected error for file without frame offsets; %+v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package smk
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Block types, as stored in the 2 least significant bits of block type
+// descriptors.
+const (
+	// Two-colour block; the colours are stored in the mono blocks colours tree
+	// and the bit map of the block is stored in the mono blocks maps tree.
+	blockMono = 0
+	// Full block; the 16 colours are stored in the full blocks tree.
+	blockFull = 1
+	// Void block; the block is unchanged from the previous frame.
+	blockVoid = 2
+	// Solid block; a single colour, stored in the 8 most significant bits of
+	// the block type descriptor, fills the block.
+	blockSolid = 3
+)
+
+// blockRuns maps from the 6-bit run length index of block type descriptors to
+// the number of consecutive blocks of the block type.
+var blockRuns = [64]int{
+	1, 2, 3, 4, 5, 6, 7, 8,
+	9, 10, 11, 12, 13, 14, 15, 16,
+	17, 18, 19, 20, 21, 22, 23, 24,
+	25, 26, 27, 28, 29, 30, 31, 32,
+	33, 34, 35, 36, 37, 38, 39, 40,
+	41, 42, 43, 44, 45, 46, 47, 48,
+	49, 50, 51, 52, 53, 54, 55, 56,
+	57, 58, 59, 128, 256, 512, 1024, 2048,
+}
+
+// decodeVideo decodes the given video data of a frame into the frame buffer.
+//
+// The frame is made up of 4x4 pixel blocks, stored in row-major order. Each
+// block type descriptor, as decoded from the block type descriptors tree,
+// specifies the type of a run of consecutive blocks. Bits 0-1 of the descriptor
+// hold the block type, bits 2-7 hold the run length index, and bits 8-15 hold
+// the colour of solid blocks.
+func (f *File) decodeVideo(data []byte) error {
+	// The recently decoded values of the trees are reset for each frame, rather
+	// than carried across frames, as by the reference decoder. Thus, decoding
+	// of a frame only depends on the frame buffer and palette of the preceding
+	// frame, which allows seeking to key frames.
+	f.mmapTree.resetCache()
+	f.mclrTree.resetCache()
+	f.fullTree.resetCache()
+	f.typeTree.resetCache()
+	br := newBitReader(data)
+	stride := f.Width
+	bw := f.Width / 4
+	nblocks := bw * (f.Height / 4)
+	for blk := 0; blk < nblocks; {
+		desc, err := f.typeTree.Decode(br)
+		if err != nil {
+			return errors.WithMessage(err, "unable to decode block type descriptor")
+		}
+		run := blockRuns[(desc>>2)&0x3F]
+		switch desc & 3 {
+		case blockMono:
+			for ; run > 0 && blk < nblocks; run-- {
+				if err := f.decodeMonoBlock(f.frame, stride, (blk%bw)*4, (blk/bw)*4, br); err != nil {
+					return err
+				}
+				blk++
+			}
+		case blockFull:
+			mode := fullMode
+			if f.Signature == "SMK4" {
+				// Smacker version 4 stores the full block mode of each run.
+				if mode, err = readFullMode(br); err != nil {
+					return err
+				}
+			}
+			for ; run > 0 && blk < nblocks; run-- {
+				if err := f.decodeFullBlock(f.frame, stride, (blk%bw)*4, (blk/bw)*4, mode, br); err != nil {
+					return err
+				}
+				blk++
+			}
+		case blockVoid:
+			for ; run > 0 && blk < nblocks; run-- {
+				decodeVoidBlock(f.frame, f.frame, stride, (blk%bw)*4, (blk/bw)*4)
+				blk++
+			}
+		case blockSolid:
+			c := uint8(desc >> 8)
+			for ; run > 0 && blk < nblocks; run-- {
+				decodeSolidBlock(f.frame, stride, (blk%bw)*4, (blk/bw)*4, c)
+				blk++
+			}
+		}
+	}
+	return nil
+}
+
+// decodeMonoBlock decodes a mono block from the bitstream into the 4x4 pixel
+// block at (x, y) of dst, where stride is the distance in bytes between
+// vertically adjacent pixels.
+//
+// A mono block consists of two colours, as decoded from the mono blocks colours
+// tree, and a 16-bit map, as decoded from the mono blocks maps tree, where each
+// bit selects the colour of a pixel in row-major order, starting with the least
+// significant bit.
+func (f *File) decodeMonoBlock(dst []uint8, stride, x, y int, br *bitReader) error {
+	clr, err := f.mclrTree.Decode(br)
+	if err != nil {
+		return errors.WithMessage(err, "unable to decode mono block colours")
+	}
+	m, err := f.mmapTree.Decode(br)
+	if err != nil {
+		return errors.WithMessage(err, "unable to decode mono block map")
+	}
+	// The high byte holds the colour of set bits, and the low byte the colour
+	// of unset bits.
+	hi, lo := uint8(clr>>8), uint8(clr)
+	off := y*stride + x
+	for j := 0; j < 4; j++ {
+		row := dst[off+j*stride : off+j*stride+4]
+		for i := range row {
+			if m&1 != 0 {
+				row[i] = hi
+			} else {
+				row[i] = lo
+			}
+			m >>= 1
+		}
+	}
+	return nil
+}
+
+// Full block modes.
+const (
+	// 16 pixels; each row stored as two values.
+	fullMode = 0
+	// 2x2 pixels; each value covers two rows. Smacker version 4 only.
+	fullModeDoubled = 1
+	// 8 pixels with doubled rows; each pair of values covers two rows. Smacker
+	// version 4 only.
+	fullModeRowDoubled = 2
+)
+
+// readFullMode reads the full block mode of a run of full blocks from the
+// bitstream of a Smacker version 4 file.
+func readFullMode(br *bitReader) (int, error) {
+	bit, err := br.ReadBit()
+	if err != nil {
+		return 0, err
+	}
+	if bit == 1 {
+		return fullModeDoubled, nil
+	}
+	bit, err = br.ReadBit()
+	if err != nil {
+		return 0, err
+	}
+	if bit == 1 {
+		return fullModeRowDoubled, nil
+	}
+	return fullMode, nil
+}
+
+// decodeFullBlock decodes a full block of the given mode from the bitstream
+// into the 4x4 pixel block at (x, y) of dst, where stride is the distance in
+// bytes between vertically adjacent pixels.
+//
+// The colours of a full block are decoded from the full blocks tree, two
+// pixels per value; the low byte of each value holds the leftmost pixel.
+func (f *File) decodeFullBlock(dst []uint8, stride, x, y, mode int, br *bitReader) error {
+	off := y*stride + x
+	switch mode {
+	case fullMode:
+		// Each row is stored as two values, right half first.
+		for j := 0; j < 4; j++ {
+			right, left, err := f.decodeFullPair(br)
+			if err != nil {
+				return err
+			}
+			row := dst[off+j*stride : off+j*stride+4]
+			row[0], row[1] = uint8(left), uint8(left>>8)
+			row[2], row[3] = uint8(right), uint8(right>>8)
+		}
+	case fullModeDoubled:
+		// Each value covers two rows; the low byte fills the left half and the
+		// high byte fills the right half.
+		for j := 0; j < 4; j += 2 {
+			pix, err := f.fullTree.Decode(br)
+			if err != nil {
+				return errors.WithMessage(err, "unable to decode full block colours")
+			}
+			for k := j; k < j+2; k++ {
+				row := dst[off+k*stride : off+k*stride+4]
+				row[0], row[1] = uint8(pix), uint8(pix)
+				row[2], row[3] = uint8(pix>>8), uint8(pix>>8)
+			}
+		}
+	case fullModeRowDoubled:
+		// Each pair of values covers two rows, right half first.
+		for j := 0; j < 4; j += 2 {
+			right, left, err := f.decodeFullPair(br)
+			if err != nil {
+				return err
+			}
+			for k := j; k < j+2; k++ {
+				row := dst[off+k*stride : off+k*stride+4]
+				row[0], row[1] = uint8(left), uint8(left>>8)
+				row[2], row[3] = uint8(right), uint8(right>>8)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeFullPair decodes the right and left half of a row of a full block from
+// the bitstream, in that order.
+func (f *File) decodeFullPair(br *bitReader) (right, left uint16, err error) {
+	if right, err = f.fullTree.Decode(br); err != nil {
+		return 0, 0, errors.WithMessage(err, "unable to decode full block colours")
+	}
+	if left, err = f.fullTree.Decode(br); err != nil {
+		return 0, 0, errors.WithMessage(err, "unable to decode full block colours")
+	}
+	return right, left, nil
+}
+
+// decodeVoidBlock copies the 4x4 pixel block at (x, y) of the previous frame
+// prev into dst, where stride is the distance in bytes between vertically
+// adjacent pixels of both. A void block is a no-op when the current frame is
+// decoded in place of the previous frame; i.e. when dst and prev are the same
+// buffer.
+func decodeVoidBlock(dst, prev []uint8, stride, x, y int) {
+	if &dst[0] == &prev[0] {
+		return
+	}
+	off := y*stride + x
+	for j := 0; j < 4; j++ {
+		copy(dst[off+j*stride:off+j*stride+4], prev[off+j*stride:off+j*stride+4])
+	}
+}
+
+// decodeSolidBlock fills the 4x4 pixel block at (x, y) of dst with the colour
+// c, where stride is the distance in bytes between vertically adjacent pixels.
+func decodeSolidBlock(dst []uint8, stride, x, y int, c uint8) {
+	off := y*stride + x
+	for j := 0; j < 4; j++ {
+		row := dst[off+j*stride : off+j*stride+4]
+		row[0], row[1], row[2], row[3] = c, c, c, c
+	}
+}
@@ -0,0 +1,217 @@
+package smk
+
+import (
+	"bytes"
+	"testing"
+)
+
+// parseTestTrees returns a File with the given Huffman trees, as parsed from a
+// Smacker file.
+func parseTestTrees(t testing.TB, tt *testTrees) *File {
+	t.Helper()
+	return parseBytes(t, buildFile(t, tt.header(), tt.data, []testFrame{{key: true}}))
+}
+
+func TestDecodeMonoBlock(t *testing.T) {
+	const (
+		clr = 0x0703 // Colour 7 for set bits, and colour 3 for unset bits.
+		m   = 0xA5C3
+	)
+	tt := newTestTrees(t, []uint32{0x0000, m, 0xFFFF}, []uint32{0x0102, clr}, nil, nil)
+	f := parseTestTrees(t, tt)
+	bw := &bitWriter{}
+	tt.mclr[clr].write(bw)
+	tt.mmap[m].write(bw)
+	// Decode the block at (4, 0) of an 8x4 pixel buffer.
+	dst := bytes.Repeat([]uint8{0xEE}, 8*4)
+	if err := f.decodeMonoBlock(dst, 8, 4, 0, newBitReader(bw.Bytes())); err != nil {
+		t.Fatalf("unable to decode mono block; %+v", err)
+	}
+	// Bit map 0xA5C3 in row-major order, least significant bit first.
+	want := []uint8{
+		0xEE, 0xEE, 0xEE, 0xEE, 7, 7, 3, 3,
+		0xEE, 0xEE, 0xEE, 0xEE, 3, 3, 7, 7,
+		0xEE, 0xEE, 0xEE, 0xEE, 7, 3, 7, 3,
+		0xEE, 0xEE, 0xEE, 0xEE, 3, 7, 3, 7,
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("pixel mismatch; expected %v, got %v", want, dst)
+	}
+}
+
+func TestDecodeFullBlock(t *testing.T) {
+	block := []uint8{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	var full []uint32
+	for i := 0; i < len(block); i += 2 {
+		full = append(full, uint32(block[i])|uint32(block[i+1])<<8)
+	}
+	tt := newTestTrees(t, nil, nil, full, nil)
+	f := parseTestTrees(t, tt)
+	bw := &bitWriter{}
+	for y := 0; y < 4; y++ {
+		tt.writeFullRow(bw, block[4*y:4*y+4])
+	}
+	dst := make([]uint8, 4*4)
+	if err := f.decodeFullBlock(dst, 4, 0, 0, fullMode, newBitReader(bw.Bytes())); err != nil {
+		t.Fatalf("unable to decode full block; %+v", err)
+	}
+	if !bytes.Equal(dst, block) {
+		t.Errorf("pixel mismatch; expected %v, got %v", block, dst)
+	}
+}
+
+func TestDecodeFullModes(t *testing.T) {
+	// Full tree values; the low byte is the left pixel of each value.
+	var full []uint32
+	for i := uint32(1); i < 32; i += 2 {
+		full = append(full, i|(i+1)<<8)
+	}
+	// Block type descriptor of a run of 2 full blocks.
+	const desc = 1<<2 | blockFull
+	tt := newTestTrees(t, nil, nil, full, []uint32{desc})
+	val := func(lo uint8) uint32 {
+		return uint32(lo) | uint32(lo+1)<<8
+	}
+	golden := []struct {
+		name string
+		// Full block mode bits of the run, in order.
+		bits []uint32
+		// Values of the full tree, in order.
+		vals []uint32
+		// Pixels of the 8x4 frame.
+		want []uint8
+	}{
+		{
+			// Each row is stored as two values, right half first.
+			name: "full",
+			bits: []uint32{0, 0},
+			vals: []uint32{
+				val(3), val(1), val(7), val(5), val(11), val(9), val(15), val(13),
+				val(19), val(17), val(23), val(21), val(27), val(25), val(31), val(29),
+			},
+			want: []uint8{
+				1, 2, 3, 4, 17, 18, 19, 20,
+				5, 6, 7, 8, 21, 22, 23, 24,
+				9, 10, 11, 12, 25, 26, 27, 28,
+				13, 14, 15, 16, 29, 30, 31, 32,
+			},
+		},
+		{
+			// Each value covers 2x2 pixels of two rows.
+			name: "doubled",
+			bits: []uint32{1},
+			vals: []uint32{val(1), val(3), val(5), val(7)},
+			want: []uint8{
+				1, 1, 2, 2, 5, 5, 6, 6,
+				1, 1, 2, 2, 5, 5, 6, 6,
+				3, 3, 4, 4, 7, 7, 8, 8,
+				3, 3, 4, 4, 7, 7, 8, 8,
+			},
+		},
+		{
+			// Each pair of values covers two rows, right half first.
+			name: "row doubled",
+			bits: []uint32{0, 1},
+			vals: []uint32{val(3), val(1), val(7), val(5), val(11), val(9), val(15), val(13)},
+			want: []uint8{
+				1, 2, 3, 4, 9, 10, 11, 12,
+				1, 2, 3, 4, 9, 10, 11, 12,
+				5, 6, 7, 8, 13, 14, 15, 16,
+				5, 6, 7, 8, 13, 14, 15, 16,
+			},
+		},
+	}
+	for _, g := range golden {
+		bw := &bitWriter{}
+		// The block type descriptor is the only value of its tree, and is thus
+		// encoded using no bits.
+		for _, bit := range g.bits {
+			bw.WriteBit(bit)
+		}
+		for _, v := range g.vals {
+			tt.full[v].write(bw)
+		}
+		hdr := tt.header()
+		hdr.Signature = "SMK4"
+		hdr.Width, hdr.Height = 8, 4
+		f := parseBytes(t, buildFile(t, hdr, tt.data, []testFrame{{key: true, data: bw.Bytes()}}))
+		img, err := f.DecodeFrame(0)
+		if err != nil {
+			t.Fatalf("%s: unable to decode frame; %+v", g.name, err)
+		}
+		if !bytes.Equal(img.Pix, g.want) {
+			t.Errorf("%s: pixel mismatch; expected %v, got %v", g.name, g.want, img.Pix)
+		}
+	}
+}
+
+func TestDecodeSolidBlock(t *testing.T) {
+	dst := make([]uint8, 8*4)
+	decodeSolidBlock(dst, 8, 0, 0, 9)
+	want := []uint8{
+		9, 9, 9, 9, 0, 0, 0, 0,
+		9, 9, 9, 9, 0, 0, 0, 0,
+		9, 9, 9, 9, 0, 0, 0, 0,
+		9, 9, 9, 9, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("pixel mismatch; expected %v, got %v", want, dst)
+	}
+}
+
+func TestDecodeVoidBlock(t *testing.T) {
+	prev := make([]uint8, 8*4)
+	for i := range prev {
+		prev[i] = uint8(i)
+	}
+	dst := make([]uint8, 8*4)
+	decodeVoidBlock(dst, prev, 8, 4, 0)
+	want := []uint8{
+		0, 0, 0, 0, 4, 5, 6, 7,
+		0, 0, 0, 0, 12, 13, 14, 15,
+		0, 0, 0, 0, 20, 21, 22, 23,
+		0, 0, 0, 0, 28, 29, 30, 31,
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("pixel mismatch; expected %v, got %v", want, dst)
+	}
+	// Decoding in place of the previous frame leaves the block unchanged.
+	decodeVoidBlock(prev, prev, 8, 4, 0)
+	for i := range prev {
+		if prev[i] != uint8(i) {
+			t.Fatalf("pixel %d changed by in-place void block; got %d", i, prev[i])
+		}
+	}
+}
+
+func TestDecodeVideoRuns(t *testing.T) {
+	// A run of three solid blocks followed by a full block, in a 16x4 frame.
+	const (
+		solid5Run3 = blockSolid | 2<<2 | 5<<8
+		fullRun1   = blockFull
+	)
+	tt := newTestTrees(t, nil, nil, []uint32{0x0201}, []uint32{solid5Run3, fullRun1})
+	bw := &bitWriter{}
+	tt.typ[solid5Run3].write(bw)
+	tt.typ[fullRun1].write(bw)
+	for y := 0; y < 4; y++ {
+		tt.writeFullRow(bw, []uint8{1, 2, 1, 2})
+	}
+	hdr := tt.header()
+	hdr.Width, hdr.Height = 16, 4
+	f := parseBytes(t, buildFile(t, hdr, tt.data, []testFrame{{key: true, data: bw.Bytes()}}))
+	img, err := f.DecodeFrame(0)
+	if err != nil {
+		t.Fatalf("unable to decode frame; %+v", err)
+	}
+	row := []uint8{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 1, 2, 1, 2}
+	want := bytes.Repeat(row, 4)
+	if !bytes.Equal(img.Pix, want) {
+		t.Errorf("pixel mismatch; expected %v, got %v", want, img.Pix)
+	}
+}
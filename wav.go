@@ -0,0 +1,162 @@
+package smk
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// WriteTrackWAV decodes the audio data of the given track from every frame of
+// the Smacker file, and writes it to w as a RIFF WAVE stream of PCM samples.
+// The sample format of the track is retained; as for WAVE, 8-bit samples are
+// unsigned and 16-bit samples are signed.
+//
+// The decoded samples are buffered in memory, as the sizes stored in the WAVE
+// header are only known once all frames have been decoded.
+func (f *File) WriteTrackWAV(w io.Writer, track int) error {
+	if track < 0 || track >= len(f.TrackInfo) {
+		return errors.Errorf("invalid audio track; expected 0 <= track < %d, got %d", len(f.TrackInfo), track)
+	}
+	info := f.TrackInfo[track]
+	if !info.HasAudioData() {
+		return errors.Errorf("no audio data present for track %d", track)
+	}
+	data, err := f.DecodeAllAudioContext(context.Background(), track)
+	if err != nil {
+		return err
+	}
+	if err := writeWAVHeader(w, info, len(data)); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	return writeWAVPad(w, len(data))
+}
+
+// WriteTracksWAV decodes the audio data of every track with audio data present
+// from every frame of the Smacker file, and writes each track as a RIFF WAVE
+// stream of PCM samples, as for WriteTrackWAV, to the writer returned by open
+// for the track. Closing the writers is left to the caller.
+//
+// The frames are decoded once for all tracks, and the samples are written as
+// they are decoded; the sizes stored in the WAVE header of each track are
+// updated by seeking once all frames have been decoded.
+func (f *File) WriteTracksWAV(open func(track int) (io.WriteSeeker, error)) error {
+	var tracks []int
+	ws := make(map[int]io.WriteSeeker)
+	for track, info := range f.TrackInfo {
+		if !info.HasAudioData() {
+			continue
+		}
+		w, err := open(track)
+		if err != nil {
+			return errors.WithMessagef(err, "unable to open WAVE output of track %d", track)
+		}
+		// Placeholder header, updated once the data size is known.
+		if err := writeWAVHeader(w, info, 0); err != nil {
+			return err
+		}
+		tracks = append(tracks, track)
+		ws[track] = w
+	}
+	sizes := make(map[int]int)
+	for i := 0; i < f.NFrames; i++ {
+		for _, track := range tracks {
+			samples, err := f.DecodeAudio(track, i)
+			if err != nil {
+				return err
+			}
+			if _, err := ws[track].Write(samples); err != nil {
+				return errors.WithStack(err)
+			}
+			sizes[track] += len(samples)
+		}
+	}
+	for _, track := range tracks {
+		w := ws[track]
+		if err := writeWAVPad(w, sizes[track]); err != nil {
+			return err
+		}
+		if _, err := w.Seek(0, io.SeekStart); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := writeWAVHeader(w, f.TrackInfo[track], sizes[track]); err != nil {
+			return err
+		}
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// wavHeader is the header of a RIFF WAVE stream of PCM samples.
+type wavHeader struct {
+	// "RIFF".
+	RIFFID [4]byte
+	// Size of the RIFF chunk, excluding the RIFF ID and size, and including the
+	// pad byte of the data chunk.
+	RIFFSize uint32
+	// "WAVE".
+	WAVEID [4]byte
+	// "fmt ".
+	FmtID [4]byte
+	// Size of the format chunk (16).
+	FmtSize uint32
+	// Audio format (1 = PCM).
+	AudioFormat uint16
+	// Number of channels.
+	NChannels uint16
+	// Sample rate in Hz.
+	SampleRate uint32
+	// Bytes per second.
+	ByteRate uint32
+	// Bytes per sample frame, covering all channels.
+	BlockAlign uint16
+	// Bits per sample.
+	BitsPerSample uint16
+	// "data".
+	DataID [4]byte
+	// Size of the sample data in bytes, excluding the pad byte.
+	DataSize uint32
+}
+
+// writeWAVHeader writes a RIFF WAVE header to w for dataSize bytes of PCM
+// samples of the given audio format.
+func writeWAVHeader(w io.Writer, info TrackInfo, dataSize int) error {
+	blockAlign := info.NChannels() * info.BitRate() / 8
+	hdr := wavHeader{
+		RIFFID:        [4]byte{'R', 'I', 'F', 'F'},
+		RIFFSize:      uint32(36 + dataSize + dataSize&1),
+		WAVEID:        [4]byte{'W', 'A', 'V', 'E'},
+		FmtID:         [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   1,
+		NChannels:     uint16(info.NChannels()),
+		SampleRate:    uint32(info.SampleRate()),
+		ByteRate:      uint32(info.SampleRate() * blockAlign),
+		BlockAlign:    uint16(blockAlign),
+		BitsPerSample: uint16(info.BitRate()),
+		DataID:        [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(dataSize),
+	}
+	if err := binary.Write(w, binary.LittleEndian, &hdr); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writeWAVPad writes the pad byte following dataSize bytes of PCM samples to w
+// if dataSize is odd, as RIFF chunks are aligned to even sizes.
+func writeWAVPad(w io.Writer, dataSize int) error {
+	if dataSize&1 == 0 {
+		return nil
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestWriteTrackWAV(t *testing.T) {
+	golden := []struct {
+		info    TrackInfo
+		samples []int
+		is16    bool
+		// Size of sample data in bytes.
+		size int
+	}{
+		// Odd data size, followed by a pad byte.
+		{info: testMono8, samples: []int{128, 130, 126, 0, 255}, size: 5},
+		{info: testMono16, samples: []int{0, 1000, -1000}, is16: true, size: 6},
+	}
+	for _, g := range golden {
+		hdr := FileHeader{}
+		hdr.TrackInfo[0] = g.info
+		hdr.AudioSize[0] = g.size
+		chunk := audioChunk(encodeAudio(g.samples, 1, g.is16))
+		raw := buildFile(t, hdr, absentTrees, []testFrame{
+			{key: true, typ: FrameTypeAudioDataTrack0, data: chunk},
+		})
+		f := parseBytes(t, raw)
+		buf := &bytes.Buffer{}
+		if err := f.WriteTrackWAV(buf, 0); err != nil {
+			t.Fatalf("%d-bit: unable to write WAVE stream; %+v", g.info.BitRate(), err)
+		}
+		checkWAV(t, buf.Bytes(), g.info, g.size)
+	}
+}
+
+// checkWAV checks the header and size of the given RIFF WAVE stream of size
+// bytes of PCM samples in the audio format of info.
+func checkWAV(t *testing.T, wav []byte, info TrackInfo, size int) {
+	t.Helper()
+	var hdr wavHeader
+	if err := binary.Read(bytes.NewReader(wav), binary.LittleEndian, &hdr); err != nil {
+		t.Fatalf("unable to read WAVE header; %v", err)
+	}
+	pad := size & 1
+	if want := 44 + size + pad; len(wav) != want {
+		t.Errorf("WAVE stream size mismatch; expected %d, got %d", want, len(wav))
+	}
+	if want := uint32(36 + size + pad); hdr.RIFFSize != want {
+		t.Errorf("RIFF size mismatch; expected %d, got %d", want, hdr.RIFFSize)
+	}
+	if hdr.DataSize != uint32(size) {
+		t.Errorf("data size mismatch; expected %d, got %d", size, hdr.DataSize)
+	}
+	if pad != 0 && wav[len(wav)-1] != 0 {
+		t.Errorf("expected zero pad byte, got 0x%02X", wav[len(wav)-1])
+	}
+	if int(hdr.NChannels) != info.NChannels() || int(hdr.BitsPerSample) != info.BitRate() || int(hdr.SampleRate) != info.SampleRate() {
+		t.Errorf("audio format mismatch; expected %d channels of %d-bit at %d Hz, got %d channels of %d-bit at %d Hz", info.NChannels(), info.BitRate(), info.SampleRate(), hdr.NChannels, hdr.BitsPerSample, hdr.SampleRate)
+	}
+	if want := uint16(info.NChannels() * info.BitRate() / 8); hdr.BlockAlign != want {
+		t.Errorf("block align mismatch; expected %d, got %d", want, hdr.BlockAlign)
+	}
+}
+
+func TestWriteTrackWAV8(t *testing.T) {
+	// Mono 8-bit audio of an initial sample and eleven sample deltas, which
+	// wrap around rather than being clipped.
+	deltas := []uint32{3, 5, 0xFE, 0xFF, 1, 0x80, 0x80, 0x7F, 0, 0x10, 0xF0}
+	want := []byte{250, 253, 2, 0, 255, 0, 128, 0, 127, 127, 143, 127}
+	bw := &bitWriter{}
+	bw.WriteBit(1) // present
+	bw.WriteBit(0) // mono
+	bw.WriteBit(0) // 8-bit
+	codes := writeByteTree(bw, uniq(deltas))
+	bw.WriteBits(uint32(want[0]), 8)
+	for _, delta := range deltas {
+		codes[delta].write(bw)
+	}
+	data := make([]byte, 4, 4+len(bw.Bytes()))
+	binary.LittleEndian.PutUint32(data, uint32(len(want)))
+	data = append(data, bw.Bytes()...)
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testMono8
+	hdr.AudioSize[0] = len(want)
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack0, data: audioChunk(data)},
+	})
+	buf := &bytes.Buffer{}
+	if err := parseBytes(t, raw).WriteTrackWAV(buf, 0); err != nil {
+		t.Fatalf("unable to write WAVE stream; %+v", err)
+	}
+	checkWAV(t, buf.Bytes(), testMono8, len(want))
+	// Unsigned 8-bit samples, as stored in WAVE.
+	if got := buf.Bytes()[44:]; !bytes.Equal(got, want) {
+		t.Errorf("sample mismatch; expected %v, got %v", want, got)
+	}
+}
+
+// memFile is an in-memory io.WriteSeeker.
+type memFile struct {
+	buf []byte
+	off int
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	if n := m.off + len(p); n > len(m.buf) {
+		m.buf = append(m.buf, make([]byte, n-len(m.buf))...)
+	}
+	copy(m.buf[m.off:], p)
+	m.off += len(p)
+	return len(p), nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		m.off = int(offset)
+	case io.SeekCurrent:
+		m.off += int(offset)
+	case io.SeekEnd:
+		m.off = len(m.buf) + int(offset)
+	}
+	return int64(m.off), nil
+}
+
+func TestWriteTracksWAV(t *testing.T) {
+	stereo := audioChunk(encodeAudio([]int{128, 10, 130, 20, 255, 0, 0, 255}, 2, false))
+	// Odd data size of track 2, followed by a pad byte.
+	mono := audioChunk(encodeAudio([]int{1, 2, 3, 4, 5}, 1, false))
+	hdr := FileHeader{}
+	hdr.TrackInfo[0] = testStereo8
+	hdr.TrackInfo[2] = testMono8
+	hdr.AudioSize[0] = 8
+	hdr.AudioSize[2] = 5
+	raw := buildFile(t, hdr, absentTrees, []testFrame{
+		{key: true, typ: FrameTypeAudioDataTrack0 | FrameTypeAudioDataTrack2, data: append(append([]byte{}, stereo...), mono...)},
+		{typ: FrameTypeAudioDataTrack0, data: stereo},
+	})
+	files := make(map[int]*memFile)
+	err := parseBytes(t, raw).WriteTracksWAV(func(track int) (io.WriteSeeker, error) {
+		files[track] = &memFile{}
+		return files[track], nil
+	})
+	if err != nil {
+		t.Fatalf("unable to write WAVE streams; %+v", err)
+	}
+	if len(files) != 2 || files[0] == nil || files[2] == nil {
+		t.Fatalf("expected WAVE streams of track 0 and 2, got %d streams", len(files))
+	}
+	for track, size := range map[int]int{0: 16, 2: 5} {
+		wav := files[track].buf
+		checkWAV(t, wav, hdr.TrackInfo[track], size)
+		// Identical to the WAVE stream of the track written on its own.
+		want := &bytes.Buffer{}
+		if err := parseBytes(t, raw).WriteTrackWAV(want, track); err != nil {
+			t.Fatalf("track %d: unable to write WAVE stream; %+v", track, err)
+		}
+		if !bytes.Equal(wav, want.Bytes()) {
+			t.Errorf("track %d: WAVE stream mismatch; expected %v, got %v", track, want.Bytes(), wav)
+		}
+	}
+}